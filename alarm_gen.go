@@ -0,0 +1,108 @@
+package ical
+
+// THIS FILE IS AUTO-GENERATED BY internal/cmd/gentypes/gentypes.go
+// DO NOT EDIT. ALL CHANGES WILL BE LOST
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type Alarm struct {
+	entries EntryList
+	props   *PropertySet
+}
+
+func NewAlarm() *Alarm {
+	return &Alarm{
+		props: NewPropertySet(),
+	}
+}
+
+func (v *Alarm) String() string {
+	var buf bytes.Buffer
+	NewEncoder(&buf).Encode(v)
+	return buf.String()
+}
+
+func (v Alarm) Type() string {
+	return "VALARM"
+}
+
+func (v *Alarm) AddEntry(e Entry) error {
+	v.entries.Append(e)
+	return nil
+}
+
+func (v *Alarm) Entries() <-chan Entry {
+	return v.entries.Iterator()
+}
+
+func (v *Alarm) GetProperty(name string) (*Property, bool) {
+	return v.props.GetFirst(name)
+}
+
+func (v *Alarm) Properties() <-chan *Property {
+	return v.props.Iterator()
+}
+
+func (v *Alarm) AddProperty(key, value string, options ...PropertyOption) error {
+	var params Parameters
+	var values []string
+	var force bool
+	for _, option := range options {
+		switch option.Name() {
+		case "Parameters":
+			params = option.Get().(Parameters)
+		case "Values":
+			values = option.Get().([]string)
+		case "Force":
+			force = option.Get().(bool)
+		}
+	}
+
+	switch key = strings.ToLower(key); key {
+	case "action", "trigger", "description", "duration", "repeat", "summary":
+		v.props.Set(newProperty(key, value, params, values))
+	case "attach", "attendee":
+		v.props.Append(newProperty(key, value, params, values))
+	default:
+		if strings.HasPrefix(key, "x-") || force {
+			v.props.Append(newProperty(key, value, params, values))
+		} else {
+			return errors.Errorf(`invalid property %s`, key)
+		} /* end if */
+	}
+	return nil
+}
+
+func (v *Alarm) MarshalJSON() ([]byte, error) {
+	var dst bytes.Buffer
+	if err := NewJSONEncoder(&dst).Encode(v); err != nil {
+		return nil, errors.Wrap(err, `failed to encode json`)
+	}
+	return dst.Bytes(), nil
+}
+
+// Validate reports the violations of RFC 5545 found in the component
+// and its sub-components as ValidationErrors
+func (v *Alarm) Validate() error {
+	return validateEntry(v)
+}
+
+func (v *Alarm) validateProperties(path string) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range []string{"action", "trigger"} {
+		if _, ok := v.props.GetFirst(name); !ok {
+			errs = append(errs, newValidationError(path, name, `missing mandatory property`))
+		}
+	}
+	_, okduration := v.props.GetFirst("duration")
+	_, okrepeat := v.props.GetFirst("repeat")
+	if okduration != okrepeat {
+		errs = append(errs, newValidationError(path, "repeat", `must be specified together with duration`))
+	}
+	return errs
+}
@@ -0,0 +1,213 @@
+package ical
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Attachment is an ATTACH property, which refers to a document either
+// by URI or by including its data inline
+type Attachment struct {
+	prop *Property
+}
+
+// binaryValue is the data of a BINARY property that was created from
+// raw bytes or a reader, which is encoded to base64 only as it is
+// written
+type binaryValue struct {
+	data []byte
+	r    io.Reader
+	read bool // r has been consumed
+}
+
+// reader returns the data. A reader that can not be rewound can only be
+// read once
+func (v *binaryValue) reader() (io.Reader, error) {
+	if v.r == nil {
+		return bytes.NewReader(v.data), nil
+	}
+	if s, ok := v.r.(io.Seeker); ok {
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			return nil, errors.Wrap(err, `failed to rewind attachment data`)
+		}
+		return v.r, nil
+	}
+	if v.read {
+		return nil, errors.New(`attachment data has already been read`)
+	}
+	v.read = true
+	return v.r, nil
+}
+
+// bytes returns the data as a whole. Data that can only be read once
+// is retained, so that it remains available
+func (v *binaryValue) bytes() ([]byte, error) {
+	if v.r == nil {
+		return v.data, nil
+	}
+	r, err := v.reader()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to read attachment data`)
+	}
+	if _, ok := v.r.(io.Seeker); !ok {
+		v.data, v.r = b, nil
+	}
+	return b, nil
+}
+
+func attachmentParameters(fmttype, filename string) Parameters {
+	params := Parameters{}
+	if fmttype != "" {
+		params.Set("FMTTYPE", fmttype)
+	}
+	if filename != "" {
+		params.Set("FILENAME", filename)
+	}
+	return params
+}
+
+// NewBinaryAttachment creates an attachment that includes data inline.
+// fmttype (the media type) and filename may be empty
+func NewBinaryAttachment(data []byte, fmttype, filename string) *Attachment {
+	return newBinaryAttachment(&binaryValue{data: data}, fmttype, filename)
+}
+
+// NewBinaryAttachmentReader creates an attachment that includes the data
+// read from r inline. The data is only read, and encoded to base64, as
+// the attachment is written by an Encoder. Unless r implements
+// io.Seeker, it can only be read once
+func NewBinaryAttachmentReader(r io.Reader, fmttype, filename string) *Attachment {
+	return newBinaryAttachment(&binaryValue{r: r}, fmttype, filename)
+}
+
+func newBinaryAttachment(v *binaryValue, fmttype, filename string) *Attachment {
+	params := attachmentParameters(fmttype, filename)
+	params.Set("ENCODING", "BASE64")
+	params.Set("VALUE", string(ValueBinary))
+	p := newProperty("attach", "", params, nil)
+	p.binary = v
+	return &Attachment{prop: p}
+}
+
+// NewURIAttachment creates an attachment that refers to a document by
+// its URI. fmttype may be empty
+func NewURIAttachment(u *url.URL, fmttype string) *Attachment {
+	return &Attachment{prop: newProperty("attach", u.String(), attachmentParameters(fmttype, ""), nil)}
+}
+
+// Property returns the ATTACH property of the attachment
+func (a *Attachment) Property() *Property {
+	return a.prop
+}
+
+// IsBinary reports whether the attachment includes its data inline
+func (a *Attachment) IsBinary() bool {
+	if a.prop.binary != nil || a.prop.ValueType() == ValueBinary {
+		return true
+	}
+	enc, _ := a.prop.params.Get("ENCODING")
+	return strings.EqualFold(enc, "BASE64")
+}
+
+// FormatType returns the media type of the attachment, if known
+func (a *Attachment) FormatType() string {
+	v, _ := a.prop.params.Get("FMTTYPE")
+	return v
+}
+
+// Filename returns the suggested file name of the attachment, if any
+func (a *Attachment) Filename() string {
+	v, _ := a.prop.params.Get("FILENAME")
+	return v
+}
+
+// URI returns the URI of an attachment that refers to a document
+func (a *Attachment) URI() (*url.URL, error) {
+	if a.IsBinary() {
+		return nil, errors.New(`attachment has inline data, not a URI`)
+	}
+	u, err := url.Parse(a.prop.value)
+	if err != nil {
+		return nil, errors.Wrapf(err, `invalid uri value '%s'`, a.prop.value)
+	}
+	return u, nil
+}
+
+// Open returns a reader for the decoded data of an attachment that
+// includes its data inline
+func (a *Attachment) Open() (io.Reader, error) {
+	if !a.IsBinary() {
+		return nil, errors.New(`attachment refers to a URI, and has no inline data`)
+	}
+	if a.prop.binary != nil {
+		return a.prop.binary.reader()
+	}
+	return base64.NewDecoder(base64.StdEncoding, strings.NewReader(a.prop.value)), nil
+}
+
+// Bytes returns the decoded data of an attachment that includes its data
+// inline
+func (a *Attachment) Bytes() ([]byte, error) {
+	if a.prop.binary != nil {
+		return a.prop.binary.bytes()
+	}
+	r, err := a.Open()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, `invalid base64 value`)
+	}
+	return b, nil
+}
+
+// entryAttachments returns the ATTACH properties of props as attachments
+func entryAttachments(props *PropertySet) []*Attachment {
+	l, _ := props.Get("attach")
+	attachments := make([]*Attachment, len(l))
+	for i, p := range l {
+		attachments[i] = &Attachment{prop: p}
+	}
+	return attachments
+}
+
+// AddAttachment adds an ATTACH property to the event
+func (v *Event) AddAttachment(a *Attachment) {
+	v.props.Append(a.prop)
+}
+
+// Attachments returns the ATTACH properties of the event
+func (v *Event) Attachments() []*Attachment {
+	return entryAttachments(v.props)
+}
+
+// AddAttachment adds an ATTACH property to the to-do
+func (v *Todo) AddAttachment(a *Attachment) {
+	v.props.Append(a.prop)
+}
+
+// Attachments returns the ATTACH properties of the to-do
+func (v *Todo) Attachments() []*Attachment {
+	return entryAttachments(v.props)
+}
+
+// AddAttachment adds an ATTACH property to the alarm
+func (v *Alarm) AddAttachment(a *Attachment) {
+	v.props.Append(a.prop)
+}
+
+// Attachments returns the ATTACH properties of the alarm
+func (v *Alarm) Attachments() []*Attachment {
+	return entryAttachments(v.props)
+}
@@ -0,0 +1,171 @@
+package ical_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+// onceReader hides the io.Seeker implementation of its reader
+type onceReader struct {
+	r io.Reader
+}
+
+func (r onceReader) Read(b []byte) (int, error) {
+	return r.r.Read(b)
+}
+
+func TestAttachment(t *testing.T) {
+	data := bytes.Repeat([]byte("binary\x00data\xff"), 10000)
+
+	// the reference output is that of the plain property
+	ref := ical.NewEvent()
+	ref.AddProperty("uid", "attach@example.com")
+	ref.AddProperty("attach", base64.StdEncoding.EncodeToString(data), ical.WithParameters(ical.Parameters{
+		"ENCODING": []string{"BASE64"},
+		"VALUE":    []string{"BINARY"},
+		"FMTTYPE":  []string{"image/png"},
+		"FILENAME": []string{"画像ファイル.png"},
+	}))
+	expect := ref.String()
+
+	for name, a := range map[string]*ical.Attachment{
+		"bytes":  ical.NewBinaryAttachment(data, "image/png", "画像ファイル.png"),
+		"reader": ical.NewBinaryAttachmentReader(bytes.NewReader(data), "image/png", "画像ファイル.png"),
+	} {
+		e := ical.NewEvent()
+		e.AddProperty("uid", "attach@example.com")
+		e.AddAttachment(a)
+
+		for i := 0; i < 2; i++ {
+			if !assert.Equal(t, expect, e.String(), `%s: output #%d should match`, name, i) {
+				return
+			}
+		}
+		b, err := e.Attachments()[0].Bytes()
+		if !assert.NoError(t, err, `%s: Bytes should succeed`, name) {
+			return
+		}
+		if !assert.Equal(t, data, b, `%s: data should match`, name) {
+			return
+		}
+	}
+
+	c, err := ical.NewParser().Parse(strings.NewReader("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//Example//EN\r\n" + expect + "END:VCALENDAR\r\n"))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+	for e := range c.Entries() {
+		l := e.(*ical.Event).Attachments()
+		if !assert.Len(t, l, 1, `there should be one attachment`) {
+			return
+		}
+		a := l[0]
+		if !assert.True(t, a.IsBinary(), `attachment should be binary`) {
+			return
+		}
+		if !assert.Equal(t, "image/png", a.FormatType(), `format type should match`) {
+			return
+		}
+		if !assert.Equal(t, "画像ファイル.png", a.Filename(), `file name should match`) {
+			return
+		}
+		r, err := a.Open()
+		if !assert.NoError(t, err, `Open should succeed`) {
+			return
+		}
+		b, err := ioutil.ReadAll(r)
+		if !assert.NoError(t, err, `reading should succeed`) {
+			return
+		}
+		if !assert.Equal(t, data, b, `decoded data should match`) {
+			return
+		}
+		if _, err := a.URI(); !assert.Error(t, err, `URI should fail for binary attachments`) {
+			return
+		}
+	}
+
+	// a reader that can not be rewound is consumed by the first Encode
+	e := ical.NewTodo()
+	e.AddAttachment(ical.NewBinaryAttachmentReader(onceReader{bytes.NewReader(data)}, "", ""))
+	var buf bytes.Buffer
+	if !assert.NoError(t, ical.NewEncoder(&buf).Encode(e), `first Encode should succeed`) {
+		return
+	}
+	if !assert.Error(t, ical.NewEncoder(&buf).Encode(e), `second Encode should fail`) {
+		return
+	}
+
+	u, _ := url.Parse("https://example.com/agenda.pdf")
+	alarm := ical.NewAlarm()
+	alarm.AddAttachment(ical.NewURIAttachment(u, "application/pdf"))
+	if !assert.Contains(t, alarm.String(), "ATTACH;FMTTYPE=application/pdf:https://example.com/agenda.pdf\r\n", `URI attachment should be written`) {
+		return
+	}
+	a := alarm.Attachments()[0]
+	if !assert.False(t, a.IsBinary(), `attachment should not be binary`) {
+		return
+	}
+	if got, err := a.URI(); !assert.NoError(t, err, `URI should succeed`) || !assert.Equal(t, u.String(), got.String(), `URI should match`) {
+		return
+	}
+	if _, err := a.Bytes(); !assert.Error(t, err, `Bytes should fail for URI attachments`) {
+		return
+	}
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.n += n
+	return n, err
+}
+
+// errReader fails every read
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New(`read failed`)
+}
+
+func TestAttachmentErrors(t *testing.T) {
+	const size = 1 << 20
+	src := &countingReader{r: bytes.NewReader(make([]byte, size))}
+	e := ical.NewEvent()
+	e.AddAttachment(ical.NewBinaryAttachmentReader(onceReader{r: src}, "", ""))
+
+	if !assert.Error(t, ical.NewEncoder(&failingWriter{fail: "ATTACH"}).Encode(e), `Encode should fail`) {
+		return
+	}
+	if !assert.True(t, src.n < size, `data should not be read after the write failed`) {
+		return
+	}
+
+	e = ical.NewEvent()
+	e.AddAttachment(ical.NewBinaryAttachmentReader(errReader{}, "", ""))
+	if !assert.Error(t, ical.NewJSONEncoder(ioutil.Discard).Encode(e), `jCal Encode should fail`) {
+		return
+	}
+	if !assert.Error(t, ical.NewXMLEncoder(ioutil.Discard).Encode(e), `xCal Encode should fail`) {
+		return
+	}
+
+	a := ical.NewBinaryAttachmentReader(errReader{}, "", "")
+	if !assert.Error(t, ical.AddProperty(ical.NewEvent(), a.Property()), `AddProperty should fail`) {
+		return
+	}
+}
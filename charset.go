@@ -0,0 +1,104 @@
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// sourceReader returns src as UTF-8. A byte order mark takes precedence
+// over enc, the encoding given by the user. known reports whether the
+// encoding of the input was determined by either of them
+func sourceReader(src io.Reader, enc encoding.Encoding) (r io.Reader, known bool) {
+	br := bufio.NewReader(src)
+
+	// read errors are reported by the reads that follow
+	prefix, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(prefix, []byte{0xEF, 0xBB, 0xBF}):
+		br.Discard(3)
+		return br, true
+	case bytes.HasPrefix(prefix, []byte{0xFF, 0xFE}):
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(prefix, []byte{0xFE, 0xFF}):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	}
+
+	if enc == nil {
+		return br, false
+	}
+	return transform.NewReader(br, enc.NewDecoder()), true
+}
+
+// lookupCharset returns the encoding registered under the IANA or WHATWG
+// name charset
+func lookupCharset(charset string) (encoding.Encoding, error) {
+	if enc, err := ianaindex.IANA.Encoding(charset); err == nil && enc != nil {
+		return enc, nil
+	}
+	if enc, err := htmlindex.Get(charset); err == nil {
+		return enc, nil
+	}
+	return nil, errors.Errorf(`unsupported charset %s`, charset)
+}
+
+// decodeCharset converts b from the named character set to UTF-8
+func decodeCharset(charset string, b []byte) (string, error) {
+	switch strings.ToUpper(charset) {
+	case "UTF-8", "US-ASCII", "ASCII":
+		if !utf8.Valid(b) {
+			return "", errors.Errorf(`invalid %s text`, charset)
+		}
+		return string(b), nil
+	}
+
+	enc, err := lookupCharset(charset)
+	if err != nil {
+		return "", err
+	}
+	s, err := enc.NewDecoder().Bytes(b)
+	if err != nil {
+		return "", errors.Wrapf(err, `invalid %s text`, charset)
+	}
+	return string(s), nil
+}
+
+// normalizeCharset converts the raw value of the property name to UTF-8.
+// The CHARSET parameter is honoured unless the encoding of the whole
+// input is known, in which case only values that were encoded
+// separately (such as QUOTED-PRINTABLE ones) are still in that character
+// set. Values that are not valid UTF-8 and have no known character set
+// are taken to be Windows-1252, the most common culprit
+func (ctx *parseCtx) normalizeCharset(name, val string, params Parameters, encoded bool) (string, error) {
+	if charset, ok := params.Get("CHARSET"); ok && (encoded || !ctx.charsetKnown) {
+		s, err := decodeCharset(charset, []byte(val))
+		if err == nil {
+			params.del("CHARSET")
+			return s, nil
+		}
+		if err := ctx.warn(`failed to decode %s: %s`, strings.ToUpper(name), err); err != nil {
+			return "", err
+		}
+	} else if ok {
+		params.del("CHARSET")
+	}
+
+	if utf8.ValidString(val) {
+		return val, nil
+	}
+	if err := ctx.warn(`%s is not valid UTF-8, decoded as windows-1252`, strings.ToUpper(name)); err != nil {
+		return "", err
+	}
+	s, _ := charmap.Windows1252.NewDecoder().String(val)
+	return s, nil
+}
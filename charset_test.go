@@ -0,0 +1,116 @@
+package ical_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func charsetCalendar(summary string) string {
+	return strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VEVENT`,
+		`UID:charset@example.com`,
+		summary,
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+}
+
+func encodeString(t *testing.T, enc encoding.Encoding, s string) string {
+	b, err := enc.NewEncoder().String(s)
+	if err != nil {
+		t.Fatalf(`failed to encode: %s`, err)
+	}
+	return b
+}
+
+func TestParseCharset(t *testing.T) {
+	// the second byte of 表 in Shift_JIS is a backslash
+	const text = "表示\\, 完了"
+	const expect = "表示, 完了"
+
+	for _, tc := range []struct {
+		name    string
+		src     string
+		options []ical.ParserOption
+	}{
+		{"utf-8 bom", "\xEF\xBB\xBF" + charsetCalendar("SUMMARY:"+text), nil},
+		{"nil source encoding", charsetCalendar("SUMMARY:" + text), []ical.ParserOption{ical.WithSourceEncoding(nil)}},
+		{"utf-16le bom", encodeString(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), charsetCalendar("SUMMARY:"+text)), nil},
+		{"utf-16be bom", encodeString(t, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), charsetCalendar("SUMMARY:"+text)), nil},
+		{"source encoding", encodeString(t, japanese.ShiftJIS, charsetCalendar("SUMMARY:"+text)), []ical.ParserOption{ical.WithSourceEncoding(japanese.ShiftJIS)}},
+		{"charset parameter", charsetCalendar("SUMMARY;CHARSET=Shift_JIS:" + encodeString(t, japanese.ShiftJIS, text)), nil},
+		{"charset parameter with source encoding", encodeString(t, japanese.ShiftJIS, charsetCalendar("SUMMARY;CHARSET=Shift_JIS:"+text)), []ical.ParserOption{ical.WithSourceEncoding(japanese.ShiftJIS)}},
+	} {
+		c, warnings, err := ical.NewParser(tc.options...).ParseWithWarnings(strings.NewReader(tc.src))
+		if !assert.NoError(t, err, `%s: Parse should succeed`, tc.name) {
+			return
+		}
+		if !assert.Empty(t, warnings, `%s: there should be no warnings`, tc.name) {
+			return
+		}
+
+		var ev *ical.Event
+		for e := range c.Entries() {
+			ev = e.(*ical.Event)
+		}
+		if !assert.NotNil(t, ev, `%s: event should be parsed`, tc.name) {
+			return
+		}
+		prop, ok := ev.GetProperty("summary")
+		if !assert.True(t, ok, `%s: summary should exist`, tc.name) {
+			return
+		}
+		if !assert.Equal(t, expect, prop.RawValue(), `%s: summary should be UTF-8`, tc.name) {
+			return
+		}
+		if _, ok := prop.Parameters().Get("CHARSET"); !assert.False(t, ok, `%s: CHARSET should be removed`, tc.name) {
+			return
+		}
+
+		var buf bytes.Buffer
+		if !assert.NoError(t, ical.NewEncoder(&buf).Encode(ev), `%s: Encode should succeed`, tc.name) {
+			return
+		}
+		if !assert.Contains(t, buf.String(), "SUMMARY:表示\\, 完了\r\n", `%s: output should be UTF-8`, tc.name) {
+			return
+		}
+	}
+}
+
+func TestParseInvalidUTF8(t *testing.T) {
+	src := charsetCalendar("SUMMARY:" + encodeString(t, charmap.Windows1252, "Ça coûte 5€"))
+
+	c, l, err := ical.NewParser().ParseWithWarnings(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+	var warnings []string
+	for _, w := range l {
+		warnings = append(warnings, w.String())
+	}
+	if !assert.Equal(t, []string{`line 6: SUMMARY is not valid UTF-8, decoded as windows-1252`}, warnings, `warnings should match`) {
+		return
+	}
+	for e := range c.Entries() {
+		prop, _ := e.GetProperty("summary")
+		if !assert.Equal(t, "Ça coûte 5€", prop.RawValue(), `summary should be decoded`) {
+			return
+		}
+	}
+
+	_, err = ical.NewParser(ical.WithStrict(true)).Parse(strings.NewReader(src))
+	if !assert.Error(t, err, `Parse should fail in strict mode`) {
+		return
+	}
+}
@@ -0,0 +1,83 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Component is a component that this package has no dedicated type for,
+// such as X- components or IANA components like VAVAILABILITY. It
+// retains its name, properties and nested components as they were
+// parsed, so that they survive a round trip through the parser and
+// the encoder
+type Component struct {
+	name    string
+	entries EntryList
+	props   *PropertySet
+}
+
+func NewComponent(name string) *Component {
+	return &Component{
+		name:  strings.ToUpper(name),
+		props: NewPropertySet(),
+	}
+}
+
+func (v *Component) String() string {
+	var buf bytes.Buffer
+	NewEncoder(&buf).Encode(v)
+	return buf.String()
+}
+
+func (v Component) Type() string {
+	return v.name
+}
+
+func (v *Component) AddEntry(e Entry) error {
+	v.entries.Append(e)
+	return nil
+}
+
+func (v *Component) Entries() <-chan Entry {
+	return v.entries.Iterator()
+}
+
+func (v *Component) GetProperty(name string) (*Property, bool) {
+	return v.props.GetFirst(name)
+}
+
+func (v *Component) Properties() <-chan *Property {
+	return v.props.Iterator()
+}
+
+// AddProperty adds a property to the component. As the cardinality of
+// the properties of an unknown component can not be known, all
+// properties are treated as repeatable
+func (v *Component) AddProperty(key, value string, options ...PropertyOption) error {
+	var params Parameters
+	var values []string
+	for _, option := range options {
+		switch option.Name() {
+		case "Parameters":
+			params = option.Get().(Parameters)
+		case "Values":
+			values = option.Get().([]string)
+		}
+	}
+
+	if key == "" {
+		return errors.New(`empty property name`)
+	}
+	v.props.Append(newProperty(key, value, params, values))
+	return nil
+}
+
+func (v *Component) MarshalJSON() ([]byte, error) {
+	var dst bytes.Buffer
+	if err := NewJSONEncoder(&dst).Encode(v); err != nil {
+		return nil, errors.Wrap(err, `failed to encode json`)
+	}
+	return dst.Bytes(), nil
+}
@@ -0,0 +1,134 @@
+package ical
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// parseContentLine splits an unfolded content line into its name,
+// parameters and raw value. Parameter values may be quoted, may hold
+// multiple comma separated values, and are decoded according to
+// RFC 6868 (^n, ^' and ^^). vCalendar 1.0 content lines may also
+// contain parameters that consist of a value only
+func parseContentLine(l string, vcal10 bool) (string, Parameters, string, error) {
+	i := strings.IndexAny(l, ";:")
+	if i < 0 {
+		return "", nil, "", errors.New(`missing ':' in content line`)
+	}
+	if i == 0 {
+		return "", nil, "", errors.New(`missing property name in content line`)
+	}
+
+	name := l[:i]
+	params := Parameters{}
+	for l[i] == ';' {
+		i++
+		eq := strings.IndexAny(l[i:], "=;:")
+		if eq > 0 && vcal10 && l[i+eq] != '=' {
+			pvalue := l[i : i+eq]
+			params.Add(vcal10ParameterName(pvalue), pvalue)
+			i += eq
+			continue
+		}
+		if eq < 0 || l[i+eq] != '=' {
+			return "", nil, "", errors.New(`missing '=' in parameter of content line`)
+		}
+		pname := strings.ToUpper(l[i : i+eq])
+		if pname == "" {
+			return "", nil, "", errors.New(`missing parameter name in content line`)
+		}
+		i += eq + 1
+
+		for {
+			var pvalue string
+			if i < len(l) && l[i] == '"' {
+				end := strings.IndexByte(l[i+1:], '"')
+				if end < 0 {
+					return "", nil, "", errors.New(`unterminated quoted parameter value in content line`)
+				}
+				pvalue = l[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				end := strings.IndexAny(l[i:], ",;:")
+				if end < 0 {
+					return "", nil, "", errors.New(`missing ':' in content line`)
+				}
+				pvalue = l[i : i+end]
+				i += end
+			}
+			params.Add(pname, decodeParamValue(pvalue))
+
+			if i >= len(l) {
+				return "", nil, "", errors.New(`missing ':' in content line`)
+			}
+			if l[i] != ',' {
+				break
+			}
+			i++
+		}
+
+		if l[i] != ';' && l[i] != ':' {
+			return "", nil, "", errors.Errorf(`unexpected character '%c' after parameter value in content line`, l[i])
+		}
+	}
+
+	return name, params, l[i+1:], nil
+}
+
+// decodeParamValue decodes RFC 6868 caret escapes
+func decodeParamValue(s string) string {
+	if strings.IndexByte(s, '^') < 0 {
+		return s
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '^' && i < len(s)-1 {
+			switch s[i+1] {
+			case 'n':
+				buf.WriteByte('\n')
+				i++
+				continue
+			case '\'':
+				buf.WriteByte('"')
+				i++
+				continue
+			case '^':
+				buf.WriteByte('^')
+				i++
+				continue
+			}
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// encodeParamValue applies RFC 6868 caret escapes to s
+func encodeParamValue(s string) string {
+	if !strings.ContainsAny(s, "^\"\r\n") {
+		return s
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '^':
+			buf.WriteString("^^")
+		case '"':
+			buf.WriteString("^'")
+		case '\r':
+			if i < len(s)-1 && s[i+1] == '\n' {
+				i++
+			}
+			buf.WriteString("^n")
+		case '\n':
+			buf.WriteString("^n")
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,127 @@
+package ical
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Decoder reads a calendar one top-level component at a time, so that
+// large calendars can be processed without holding them in memory
+type Decoder struct {
+	ctx      parseCtx
+	calendar *Calendar
+	bare     bool // top-level components are read without a VCALENDAR
+	done     bool
+}
+
+// NewDecoder creates a decoder reading from src. It accepts the same
+// options as NewParser
+func NewDecoder(src io.Reader, options ...ParserOption) *Decoder {
+	dec := &Decoder{}
+	dec.ctx.init(src, NewParser(options...))
+	return dec
+}
+
+// Next returns the next top-level component of the calendar, such as a
+// VEVENT or VTIMEZONE, as soon as it has been read. Calendar properties
+// are collected along the way. io.EOF is returned once the calendar
+// has ended.
+//
+// With WithBareComponents, input that starts with a VEVENT or VTODO
+// instead of a VCALENDAR is read as a run of such components, which
+// ends at the first line that is not part of one. Calendar then returns
+// a calendar created by New
+func (dec *Decoder) Next() (Entry, error) {
+	if dec.done {
+		return nil, io.EOF
+	}
+
+	if dec.calendar == nil {
+		name, err := dec.ctx.seek(true)
+		if err != nil {
+			dec.done = true
+			if err == io.EOF {
+				err = errors.New(`no calendar found`)
+			}
+			return nil, errors.Wrap(err, `failed to parse ical`)
+		}
+		if name != "VCALENDAR" {
+			dec.calendar = New()
+			dec.bare = true
+			return dec.nextBare(name)
+		}
+		v, err := dec.ctx.open("VCALENDAR")
+		if err != nil {
+			dec.done = true
+			return nil, errors.Wrap(err, `failed to parse ical`)
+		}
+		dec.calendar = v.(*Calendar)
+	}
+
+	if dec.bare {
+		name, err := dec.ctx.seek(false)
+		if err != nil && err != io.EOF {
+			dec.done = true
+			return nil, errors.Wrap(err, `failed to parse ical`)
+		}
+		if err == io.EOF || name == "" || name == "VCALENDAR" {
+			dec.done = true
+			return nil, io.EOF
+		}
+		return dec.nextBare(name)
+	}
+
+	e, err := dec.ctx.nextItem()
+	if err != nil {
+		dec.done = true
+		return nil, errors.Wrap(err, `failed to parse ical`)
+	}
+	if e == nil {
+		dec.done = true
+		dec.ctx.close()
+		if err := dec.ctx.validate(dec.calendar); err != nil {
+			return nil, errors.Wrap(err, `failed to parse ical`)
+		}
+		return nil, io.EOF
+	}
+	if err := dec.ctx.validate(e); err != nil {
+		dec.done = true
+		return nil, errors.Wrap(err, `failed to parse ical`)
+	}
+
+	// timezones are retained, as the components that follow may refer
+	// to them
+	if tz, ok := e.(*Timezone); ok {
+		if err := dec.calendar.AddEntry(tz); err != nil {
+			dec.done = true
+			return nil, errors.Wrap(err, `failed to add timezone`)
+		}
+	}
+	return e, nil
+}
+
+// nextBare reads the top-level component name
+func (dec *Decoder) nextBare(name string) (Entry, error) {
+	e, err := dec.ctx.parse(name)
+	if err == nil {
+		err = dec.ctx.validate(e)
+	}
+	if err != nil {
+		dec.done = true
+		return nil, errors.Wrap(err, `failed to parse ical`)
+	}
+	return e, nil
+}
+
+// Calendar returns the calendar being decoded. It holds the calendar
+// properties and the VTIMEZONE components read so far, but none of the
+// other components returned by Next
+func (dec *Decoder) Calendar() *Calendar {
+	return dec.calendar
+}
+
+// Warnings returns the problems that were recovered from so far
+func (dec *Decoder) Warnings() []*Warning {
+	return dec.ctx.warnings
+}
@@ -0,0 +1,130 @@
+package ical_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoder(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VTIMEZONE`,
+		`TZID:Custom`,
+		`BEGIN:STANDARD`,
+		`DTSTART:19700101T000000`,
+		`TZOFFSETFROM:+0100`,
+		`TZOFFSETTO:+0100`,
+		`END:STANDARD`,
+		`END:VTIMEZONE`,
+		`BEGIN:VEVENT`,
+		`UID:first@example.com`,
+		`BEGIN:VALARM`,
+		`ACTION:DISPLAY`,
+		`TRIGGER:-PT15M`,
+		`END:VALARM`,
+		`END:VEVENT`,
+		`METHOD:PUBLISH`,
+		`BEGIN:VTODO`,
+		`UID:second@example.com`,
+		`END:VTODO`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	dec := ical.NewDecoder(strings.NewReader(src))
+
+	var types []string
+	for {
+		e, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err, `Next should succeed`) {
+			return
+		}
+		types = append(types, e.Type())
+
+		if e.Type() == "VEVENT" {
+			var subs []string
+			for sub := range e.Entries() {
+				subs = append(subs, sub.Type())
+			}
+			if !assert.Equal(t, []string{"VALARM"}, subs, `sub-components should be included`) {
+				return
+			}
+		}
+	}
+
+	if !assert.Equal(t, []string{"VTIMEZONE", "VEVENT", "VTODO"}, types, `components should match`) {
+		return
+	}
+
+	expect := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`METHOD:PUBLISH`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VTIMEZONE`,
+		`TZID:Custom`,
+		`BEGIN:STANDARD`,
+		`DTSTART:19700101T000000`,
+		`TZOFFSETFROM:+0100`,
+		`TZOFFSETTO:+0100`,
+		`END:STANDARD`,
+		`END:VTIMEZONE`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+	if !assert.Equal(t, expect, dec.Calendar().String(), `calendar should hold properties and timezones only`) {
+		return
+	}
+
+	if _, err := dec.Next(); !assert.Equal(t, io.EOF, err, `Next should keep returning io.EOF`) {
+		return
+	}
+}
+
+func TestDecoderBareComponents(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VEVENT`,
+		`UID:first@example.com`,
+		`END:VEVENT`,
+		`BEGIN:VTODO`,
+		`UID:second@example.com`,
+		`END:VTODO`,
+		``,
+		`BEGIN:VEVENT`,
+		`UID:third@example.com`,
+		`END:VEVENT`,
+		`--boundary--`,
+	}, "\r\n") + "\r\n"
+
+	dec := ical.NewDecoder(strings.NewReader(src), ical.WithBareComponents(true))
+
+	var uids []string
+	for {
+		e, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err, `Next should succeed`) {
+			return
+		}
+		p, _ := e.GetProperty("uid")
+		uids = append(uids, e.Type()+" "+p.RawValue())
+	}
+	if !assert.Equal(t, []string{"VEVENT first@example.com", "VTODO second@example.com", "VEVENT third@example.com"}, uids, `components should match`) {
+		return
+	}
+	if !assert.NotNil(t, dec.Calendar(), `Calendar should be synthesized`) {
+		return
+	}
+
+	if _, err := ical.NewDecoder(strings.NewReader(src)).Next(); !assert.Error(t, err, `bare components should fail without the option`) {
+		return
+	}
+}
@@ -1,33 +1,59 @@
 package ical
 
 import (
-	"encoding/json"
+	"bufio"
+	"bytes"
+	"encoding/base64"
 	"io"
 	"sort"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/pkg/errors"
 )
 
-func NewEncoder(dst io.Writer) *Encoder {
-	return &Encoder{
-		crlf: "\x0d\x0a",
-		dst:  dst,
+func NewEncoder(dst io.Writer, options ...EncoderOption) *Encoder {
+	enc := &Encoder{
+		crlf:      "\x0d\x0a",
+		dst:       dst,
+		foldWidth: defaultFoldWidth,
 	}
+	for _, option := range options {
+		option.configureEncoder(enc)
+	}
+	return enc
 }
 
-func (enc *Encoder) Encode(e Entry) error {
-	buf := bufferPool.Get()
-	defer bufferPool.Release(buf)
+// Encode writes e and all of its sub-components. Each property is
+// written as soon as it has been formatted, so the output is never held
+// in memory as a whole. On error, what has been written so far is left
+// in place, and the components that Encode began are not ended. They
+// are forgotten, so that EndComponent ends the components that were
+// begun before the call
+func (enc *Encoder) Encode(e Entry) (err error) {
+	depth := len(enc.open)
+	defer func() {
+		if err != nil {
+			enc.open = enc.open[:depth]
+		}
+	}()
 
-	buf.WriteString("BEGIN:")
-	buf.WriteString(e.Type())
-	buf.WriteString(enc.crlf)
+	if err := enc.BeginComponent(e.Type()); err != nil {
+		return err
+	}
+
+	// vCalendar 1.0 calendars are written in vCalendar 1.0 syntax as a
+	// whole, including properties that were added as iCalendar 2.0
+	if c, ok := e.(*Calendar); ok {
+		if v, ok := c.GetProperty("version"); ok && v.value == "1.0" {
+			defer func(vcal10 bool) { enc.vcal10 = vcal10 }(enc.vcal10)
+			enc.vcal10 = true
+		}
+	}
 
-	subenc := NewEncoder(buf)
 	if v, ok := e.GetProperty("version"); ok {
-		if err := subenc.EncodeProperty(v); err != nil {
+		if err := enc.EncodeProperty(v); err != nil {
 			return errors.Wrap(err, `failed to encode property 'version'`)
 		}
 	}
@@ -36,38 +62,171 @@ func (enc *Encoder) Encode(e Entry) error {
 		if prop.Name() == "version" {
 			continue
 		}
-		if err := subenc.EncodeProperty(prop); err != nil {
+		if err := enc.EncodeProperty(prop); err != nil {
 			return errors.Wrapf(err, `failed to encode property '%s'`, prop.Name())
 		}
 	}
 
+	if c, ok := e.(*Calendar); ok && enc.timezones {
+		l, err := missingTimezones(c)
+		if err != nil {
+			return errors.Wrap(err, `failed to generate timezones`)
+		}
+		for _, tz := range l {
+			if err := enc.Encode(tz); err != nil {
+				return errors.Wrap(err, `failed to encode timezone`)
+			}
+		}
+	}
+
 	for ent := range e.Entries() {
-		subenc.Encode(ent)
+		if enc.vcal10 && ent.Type() == "VALARM" {
+			if p, ok := vcal10Alarm(e, ent); ok {
+				if err := enc.EncodeProperty(p); err != nil {
+					return errors.Wrapf(err, `failed to encode property '%s'`, p.Name())
+				}
+				continue
+			}
+		}
+		if err := enc.Encode(ent); err != nil {
+			return errors.Wrapf(err, `failed to encode %s`, ent.Type())
+		}
 	}
 
-	buf.WriteString("END:")
-	buf.WriteString(e.Type())
-	buf.WriteString(enc.crlf)
+	return enc.EndComponent()
+}
 
-	_, err := buf.WriteTo(enc.dst)
-	return err
+// BeginComponent writes the BEGIN line of a component. Along with
+// EncodeProperty, Encode and EndComponent it allows writing a calendar
+// piece by piece, for example one event at a time. VTIMEZONEs are not
+// inserted automatically when writing this way
+func (enc *Encoder) BeginComponent(name string) error {
+	name = strings.ToUpper(name)
+	if _, err := io.WriteString(enc.dst, "BEGIN:"+name+enc.crlf); err != nil {
+		return errors.Wrapf(err, `failed to write BEGIN:%s`, name)
+	}
+	enc.open = append(enc.open, name)
+	return nil
+}
+
+// EndComponent writes the END line of the innermost component started
+// with BeginComponent
+func (enc *Encoder) EndComponent() error {
+	if len(enc.open) == 0 {
+		return errors.New(`no component to end`)
+	}
+	name := enc.open[len(enc.open)-1]
+	enc.open = enc.open[:len(enc.open)-1]
+	if _, err := io.WriteString(enc.dst, "END:"+name+enc.crlf); err != nil {
+		return errors.Wrapf(err, `failed to write END:%s`, name)
+	}
+	return nil
 }
 
+// EncodeProperty writes a single property. Properties of vCalendar 1.0
+// calendars are written unescaped, using QUOTED-PRINTABLE for values
+// that contain line breaks or non-ASCII characters
 func (enc *Encoder) EncodeProperty(p *Property) error {
+	if p.binary != nil {
+		return enc.encodeBinaryProperty(p)
+	}
+
+	vcal10 := p.vcal10 || enc.vcal10
+	params := p.params
+	var value string
+	var qp bool
+	if vcal10 {
+		value = vcal10Value(p)
+		if qp = needsQuotedPrintable(value); qp {
+			params = Parameters{}
+			for k, v := range p.params {
+				params[k] = v
+			}
+			params.Set("ENCODING", "QUOTED-PRINTABLE")
+			if !isASCII(value) {
+				params.Set("CHARSET", "UTF-8")
+			}
+		}
+	}
+
 	buf := bufferPool.Get()
 	defer bufferPool.Release(buf)
 
-	buf.WriteString(strings.ToUpper(p.name))
+	writePropertyHeader(buf, p.name, params)
+
+	switch {
+	case qp:
+		// In old vcal, quoted-printable properties have different
+		// folding rules: lines are broken with soft line breaks instead
+		writeQuotedPrintable(buf, value, buf.Len(), enc.crlf)
+		buf.WriteString(enc.crlf)
+		_, err := buf.WriteTo(enc.dst)
+		return err
+	case vcal10:
+		buf.WriteString(value)
+	case p.ValueType() != ValueText, isStructuredText(p.name):
+		buf.WriteString(p.value)
+	case p.values != nil:
+		for i, v := range p.values {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			escapeText(buf, v)
+		}
+	default:
+		escapeText(buf, p.value)
+	}
+
+	if enc.foldWidth <= 0 || buf.Len() <= enc.foldWidth {
+		buf.WriteString(enc.crlf)
+		_, err := buf.WriteTo(enc.dst)
+		return err
+	}
+
+	foldbuf := bufferPool.Get()
+	defer bufferPool.Release(foldbuf)
+
+	txt := buf.String()
+	if vcal10 {
+		// vCalendar 1.0 retains the whitespace that a line is folded
+		// before, so lines can only be folded where there is some
+		for len(txt) > 0 {
+			n := foldPointWhitespace(txt, enc.foldWidth)
+			foldbuf.WriteString(txt[:n])
+			foldbuf.WriteString(enc.crlf)
+			txt = txt[n:]
+		}
+		_, err := foldbuf.WriteTo(enc.dst)
+		return err
+	}
+
+	for width := enc.foldWidth; len(txt) > 0; width = enc.foldWidth - 1 {
+		if foldbuf.Len() > 0 {
+			foldbuf.WriteByte(' ')
+		}
+		n := foldPoint(txt, width)
+		foldbuf.WriteString(txt[:n])
+		foldbuf.WriteString(enc.crlf)
+		txt = txt[n:]
+	}
+	_, err := foldbuf.WriteTo(enc.dst)
+	return err
+}
+
+// writePropertyHeader writes the name and the parameters of a property,
+// up to and including the ':' that precedes the value
+func writePropertyHeader(buf *bytes.Buffer, name string, params Parameters) {
+	buf.WriteString(strings.ToUpper(name))
 
 	// parameters need to be sorted, or we risk messing up our tests
-	pnames := make([]string, 0, len(p.params))
-	for pk := range p.params {
+	pnames := make([]string, 0, len(params))
+	for pk := range params {
 		pnames = append(pnames, pk)
 	}
 
 	sort.Strings(pnames)
 	for _, pk := range pnames {
-		pvs := p.params[pk]
+		pvs := params[pk]
 		if len(pvs) == 0 { // avoid empty props
 			continue
 		}
@@ -76,9 +235,7 @@ func (enc *Encoder) EncodeProperty(p *Property) error {
 		buf.WriteString(strings.ToUpper(pk))
 		buf.WriteByte('=')
 		for i, pv := range pvs {
-			if strings.IndexByte(pv, '"') > -1 {
-				return errors.Errorf("invalid parameter value (container double quote): '%s'", pv)
-			}
+			pv = encodeParamValue(pv)
 			if strings.ContainsAny(pv, ";,:") {
 				buf.WriteByte('"')
 				buf.WriteString(pv)
@@ -92,126 +249,175 @@ func (enc *Encoder) EncodeProperty(p *Property) error {
 		}
 	}
 	buf.WriteByte(':')
+}
 
-	if !p.vcal10 {
-		v := p.value
-		for i := 0; len(v) > i; i++ {
-			switch c := v[i]; c {
-			case ';', ',':
-				if p.name != "rrule" {
-					buf.WriteByte('\\')
-				}
-				buf.WriteByte(c)
-			case '\\':
-				buf.WriteByte('\\')
-				buf.WriteByte(c)
-			case '\x0d':
-				if len(v) > i+1 && v[i+1] == '\x0a' {
-					buf.WriteString("\\n")
-					i++
-				}
-			case '\x0a':
-				buf.WriteString("\\n")
-			default:
-				buf.WriteByte(c)
-			}
-		}
+// encodeBinaryProperty writes a property that holds binary data. The
+// data is encoded to base64 and folded as it is read, so that its
+// encoded form is never held in memory
+func (enc *Encoder) encodeBinaryProperty(p *Property) error {
+	r, err := p.binary.reader()
+	if err != nil {
+		return err
 	}
 
-	fold := true
-	if p.vcal10 {
-		if v, ok := p.params.Get("ENCODING"); ok {
-			if v == "QUOTED-PRINTABLE" {
-				// skip folding. from Data::ICal's comments:
-				// In old vcal, quoted-printable properties have different folding rules.
-				// But some interop tests suggest it's wiser just to not fold for vcal 1.0
-				// at all (in quoted-printable).
-				fold = false
-			}
-		}
-	}
+	buf := bufferPool.Get()
+	defer bufferPool.Release(buf)
+	writePropertyHeader(buf, p.name, p.params)
 
-	if !fold {
-		buf.WriteString(enc.crlf)
-		_, err := buf.WriteTo(enc.dst)
-		return err
+	w := bufio.NewWriter(enc.dst)
+	fw := &foldWriter{dst: w, width: enc.foldWidth, crlf: enc.crlf}
+	if err := fw.WriteString(buf.String()); err != nil {
+		return errors.Wrap(err, `failed to write binary data`)
 	}
 
-	txt := buf.String()
-	if utf8.RuneCountInString(txt) <= 75 {
-		buf.WriteString(enc.crlf)
-		_, err := buf.WriteTo(enc.dst)
-		return err
+	b64 := base64.NewEncoder(base64.StdEncoding, fw)
+	if _, err := io.Copy(b64, r); err != nil {
+		return errors.Wrap(err, `failed to encode binary data`)
 	}
+	if err := b64.Close(); err != nil {
+		return errors.Wrap(err, `failed to encode binary data`)
+	}
+	w.WriteString(enc.crlf)
+	if err := w.Flush(); err != nil {
+		return errors.Wrap(err, `failed to write binary data`)
+	}
+	return nil
+}
 
-	foldbuf := bufferPool.Get()
-	defer bufferPool.Release(foldbuf)
+// foldWriter folds the text written to it into lines of at most width
+// octets. A width of 0 or less disables folding
+type foldWriter struct {
+	dst   *bufio.Writer
+	width int
+	crlf  string
+	col   int // length of the current line
+}
 
-	lines := 1
-	for len(txt) > 0 {
-		l := utf8.RuneCountInString(txt)
-		if l > 75 {
-			l = 75
+func (w *foldWriter) Write(b []byte) (int, error) {
+	if err := w.WriteString(string(b)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (w *foldWriter) WriteString(s string) error {
+	if w.width <= 0 {
+		_, err := w.dst.WriteString(s)
+		return err
+	}
+	for len(s) > 0 {
+		if w.col >= w.width {
+			if _, err := w.dst.WriteString(w.crlf + " "); err != nil {
+				return err
+			}
+			w.col = 1
 		}
-		if lines > 1 {
-			foldbuf.WriteByte(' ')
+		n := foldPoint(s, w.width-w.col)
+		if n > w.width-w.col && w.col > 1 {
+			// the next character does not fit
+			w.col = w.width
+			continue
 		}
-		for i := 0; i < l; i++ {
-			r, n := utf8.DecodeRuneInString(txt)
-			txt = txt[n:]
-			foldbuf.WriteRune(r)
+		if _, err := w.dst.WriteString(s[:n]); err != nil {
+			return err
 		}
-		foldbuf.WriteString(enc.crlf)
-		lines++
+		w.col += n
+		s = s[n:]
 	}
-	_, err := foldbuf.WriteTo(enc.dst)
-	return err
+	return nil
 }
 
-type JSONEncoder struct {
-	dst *json.Encoder
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
 }
 
-func NewJSONEncoder(dst io.Writer) *JSONEncoder {
-	return &JSONEncoder{
-		dst: json.NewEncoder(dst),
+// foldPointWhitespace returns the number of octets of s that go on a line
+// of at most width octets, when lines may only be folded before a space
+// or a tab. If there is no such place, the line is longer than width
+func foldPointWhitespace(s string, width int) int {
+	if len(s) <= width {
+		return len(s)
+	}
+	if i := strings.LastIndexAny(s[1:width+1], " \t"); i >= 0 {
+		return i + 1
 	}
+	if i := strings.IndexAny(s[width+1:], " \t"); i >= 0 {
+		return width + 1 + i
+	}
+	return len(s)
 }
 
-type jsprop struct {
-	Value      string     `json:"value"`
-	Parameters Parameters `json:"parameters,omitempty"`
-}
-type jsentry struct {
-	Type       string               `json:"type"`
-	Entries    []*jsentry           `json:"entries,omitempty"`
-	Properties map[string][]*jsprop `json:"properties,omitempty"`
-}
+// defaultFoldWidth is the maximum length of a line in octets, excluding
+// the line break, recommended by RFC 5545 section 3.1
+const defaultFoldWidth = 75
 
-func makeJSEntry(e Entry) *jsentry {
-	ent := &jsentry{
-		Type:       e.Type(),
-		Properties: make(map[string][]*jsprop),
+// foldPoint returns the number of octets of s that go on a line of at
+// most width octets. The line is never broken inside a UTF-8 sequence,
+// and if possible not inside a grapheme cluster either. At least one
+// character is always consumed, even if it is wider than width
+func foldPoint(s string, width int) int {
+	if len(s) <= width {
+		return len(s)
 	}
 
-	for prop := range e.Properties() {
-		l, ok := ent.Properties[prop.Name()]
-		if !ok {
-			l = []*jsprop{}
+	var lastBreak, lastRune, regional int
+	var prev rune
+	for i, r := range s {
+		if i > width {
+			break
+		}
+		if i > 0 {
+			lastRune = i
+			if !continuesCluster(prev, r, regional) {
+				lastBreak = i
+			}
+		}
+		if isRegionalIndicator(r) {
+			regional++
+		} else {
+			regional = 0
 		}
-		l = append(l, &jsprop{
-			Value: prop.RawValue(),
-			Parameters: prop.Parameters(),
-		})
-		ent.Properties[prop.Name()] = l
+		prev = r
 	}
 
-	for subent := range e.Entries() {
-		ent.Entries = append(ent.Entries, makeJSEntry(subent))
+	switch {
+	case lastBreak > 0:
+		return lastBreak
+	case lastRune > 0:
+		return lastRune
+	}
+	_, n := utf8.DecodeRuneInString(s)
+	return n
+}
+
+// continuesCluster approximates the grapheme cluster boundary rules of
+// UAX #29: combining marks, joiners, variation selectors, emoji
+// modifiers and tags attach to the preceding character, as does the
+// character following a zero width joiner. regional is the number of
+// regional indicators immediately preceding r, which pair up into flags
+func continuesCluster(prev, r rune, regional int) bool {
+	switch {
+	case prev == 0x200D: // ZERO WIDTH JOINER
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r == 0x200D, r >= 0xFE00 && r <= 0xFE0F:
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // emoji modifiers
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // tags
+		return true
+	case isRegionalIndicator(r):
+		return regional%2 == 1
 	}
-	return ent
+	return false
 }
 
-func (enc *JSONEncoder) Encode(e Entry) error {
-	return enc.dst.Encode(makeJSEntry(e))
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
 }
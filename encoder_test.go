@@ -0,0 +1,155 @@
+package ical_test
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoderStreaming(t *testing.T) {
+	c := ical.New()
+	var events []*ical.Event
+	for i := 0; i < 3; i++ {
+		e := ical.NewEvent()
+		e.AddProperty("uid", strconv.Itoa(i)+"@example.com")
+		e.AddProperty("summary", "event "+strconv.Itoa(i))
+		events = append(events, e)
+		c.AddEntry(e)
+	}
+
+	var buf bytes.Buffer
+	enc := ical.NewEncoder(&buf)
+	if !assert.NoError(t, enc.BeginComponent("VCALENDAR"), `BeginComponent should succeed`) {
+		return
+	}
+	for p := range c.Properties() {
+		if p.Name() != "version" {
+			continue
+		}
+		if !assert.NoError(t, enc.EncodeProperty(p), `EncodeProperty should succeed`) {
+			return
+		}
+	}
+	for p := range c.Properties() {
+		if p.Name() == "version" {
+			continue
+		}
+		if !assert.NoError(t, enc.EncodeProperty(p), `EncodeProperty should succeed`) {
+			return
+		}
+	}
+
+	for _, e := range events {
+		before := buf.Len()
+		if !assert.NoError(t, enc.Encode(e), `Encode should succeed`) {
+			return
+		}
+		if !assert.True(t, buf.Len() > before, `event should be written immediately`) {
+			return
+		}
+	}
+	if !assert.NoError(t, enc.EndComponent(), `EndComponent should succeed`) {
+		return
+	}
+
+	if !assert.Equal(t, c.String(), buf.String(), `streamed output should match Encode`) {
+		return
+	}
+	if !assert.Error(t, enc.EndComponent(), `EndComponent without an open component should fail`) {
+		return
+	}
+}
+
+func TestEncoderFolding(t *testing.T) {
+	family := "\U0001F468\u200d\U0001F469\u200d\U0001F467" // a single grapheme cluster
+	for _, tc := range []struct {
+		name  string
+		value string
+	}{
+		{"japanese", strings.Repeat("日本語のテキスト", 20)},
+		{"emoji", strings.Repeat("a"+family+"\U0001F1EF\U0001F1F5", 20)},
+		{"combining", strings.Repeat("e\u0301", 80)},
+	} {
+		for _, width := range []int{75, 40} {
+			e := ical.NewEvent()
+			e.AddProperty("description", tc.value)
+
+			var buf bytes.Buffer
+			if !assert.NoError(t, ical.NewEncoder(&buf, ical.WithFoldWidth(width)).Encode(e), `Encode should succeed`) {
+				return
+			}
+
+			for i, l := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+				if !assert.True(t, len(l) <= width, `%s: line %d should be at most %d octets (%d)`, tc.name, i, width, len(l)) {
+					return
+				}
+				if !assert.True(t, utf8.ValidString(l), `%s: line %d should be valid UTF-8`, tc.name, i) {
+					return
+				}
+				if strings.HasPrefix(l, " ") {
+					l = l[1:]
+					if !assert.False(t, strings.HasPrefix(l, "\u200d") || strings.HasPrefix(l, "\u0301") || strings.HasPrefix(l, "\U0001F469") || strings.HasPrefix(l, "\U0001F467") || strings.HasPrefix(l, "\U0001F1F5"), `%s: line %d should not start inside a grapheme cluster`, tc.name, i) {
+						return
+					}
+				}
+			}
+			unfolded := strings.Replace(buf.String(), "\r\n ", "", -1)
+			if !assert.Contains(t, unfolded, "DESCRIPTION:"+tc.value+"\r\n", `%s: unfolded value should match`, tc.name) {
+				return
+			}
+		}
+	}
+
+	e := ical.NewEvent()
+	e.AddProperty("description", strings.Repeat("x", 200))
+	var buf bytes.Buffer
+	if !assert.NoError(t, ical.NewEncoder(&buf, ical.WithFoldWidth(0)).Encode(e), `Encode should succeed`) {
+		return
+	}
+	if !assert.Contains(t, buf.String(), "DESCRIPTION:"+strings.Repeat("x", 200)+"\r\n", `folding should be disabled`) {
+		return
+	}
+}
+
+// failingWriter fails to write anything that contains fail
+type failingWriter struct {
+	bytes.Buffer
+	fail string
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if strings.Contains(string(p), w.fail) {
+		return 0, errors.New(`write failed`)
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestEncoderError(t *testing.T) {
+	e := ical.NewEvent()
+	e.AddProperty("uid", "error@example.com")
+	e.AddProperty("summary", "unwritable")
+
+	dst := &failingWriter{fail: "unwritable"}
+	enc := ical.NewEncoder(dst)
+	if !assert.NoError(t, enc.BeginComponent("VCALENDAR"), `BeginComponent should succeed`) {
+		return
+	}
+	if !assert.Error(t, enc.Encode(e), `Encode should fail`) {
+		return
+	}
+	if !assert.NoError(t, enc.EndComponent(), `EndComponent should succeed`) {
+		return
+	}
+	if !assert.True(t, strings.HasSuffix(dst.String(), "\r\nEND:VCALENDAR\r\n"), `EndComponent should end the calendar, not the event`) {
+		return
+	}
+	if !assert.Error(t, enc.EndComponent(), `no component should be left open`) {
+		return
+	}
+}
@@ -50,22 +50,27 @@ func (v *Event) Properties() <-chan *Property {
 
 func (v *Event) AddProperty(key, value string, options ...PropertyOption) error {
 	var params Parameters
+	var values []string
 	var force bool
 	for _, option := range options {
 		switch option.Name() {
 		case "Parameters":
 			params = option.Get().(Parameters)
+		case "Values":
+			values = option.Get().([]string)
 		case "Force":
 			force = option.Get().(bool)
 		}
 	}
 
 	switch key = strings.ToLower(key); key {
-	case "class", "created", "description", "dtstamp", "dtstart", "dtend", "duration", "geo", "last-modified", "location", "organizer", "priority", "sequence", "status", "summary", "transp", "uid", "url", "recurrence-id":
-		v.props.Set(NewProperty(key, value, params))
+	case "dtstamp", "uid", "class", "created", "description", "dtstart", "dtend", "duration", "geo", "last-modified", "location", "organizer", "priority", "sequence", "status", "summary", "transp", "url", "recurrence-id":
+		v.props.Set(newProperty(key, value, params, values))
+	case "attach", "attendee", "categories", "comment", "contact", "exdate", "exrule", "request-status", "related-to", "resources", "rdate", "rrule":
+		v.props.Append(newProperty(key, value, params, values))
 	default:
 		if strings.HasPrefix(key, "x-") || force {
-			v.props.Append(NewProperty(key, value, params))
+			v.props.Append(newProperty(key, value, params, values))
 		} else {
 			return errors.Errorf(`invalid property %s`, key)
 		} /* end if */
@@ -80,3 +85,24 @@ func (v *Event) MarshalJSON() ([]byte, error) {
 	}
 	return dst.Bytes(), nil
 }
+
+// Validate reports the violations of RFC 5545 found in the component
+// and its sub-components as ValidationErrors
+func (v *Event) Validate() error {
+	return validateEntry(v)
+}
+
+func (v *Event) validateProperties(path string) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range []string{"dtstamp", "uid"} {
+		if _, ok := v.props.GetFirst(name); !ok {
+			errs = append(errs, newValidationError(path, name, `missing mandatory property`))
+		}
+	}
+	if _, ok := v.props.GetFirst("dtend"); ok {
+		if _, ok := v.props.GetFirst("duration"); ok {
+			errs = append(errs, newValidationError(path, "duration", `may not be specified together with dtend`))
+		}
+	}
+	return errs
+}
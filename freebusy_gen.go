@@ -0,0 +1,103 @@
+package ical
+
+// THIS FILE IS AUTO-GENERATED BY internal/cmd/gentypes/gentypes.go
+// DO NOT EDIT. ALL CHANGES WILL BE LOST
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type FreeBusy struct {
+	entries EntryList
+	props   *PropertySet
+}
+
+func NewFreeBusy() *FreeBusy {
+	return &FreeBusy{
+		props: NewPropertySet(),
+	}
+}
+
+func (v *FreeBusy) String() string {
+	var buf bytes.Buffer
+	NewEncoder(&buf).Encode(v)
+	return buf.String()
+}
+
+func (v FreeBusy) Type() string {
+	return "VFREEBUSY"
+}
+
+func (v *FreeBusy) AddEntry(e Entry) error {
+	v.entries.Append(e)
+	return nil
+}
+
+func (v *FreeBusy) Entries() <-chan Entry {
+	return v.entries.Iterator()
+}
+
+func (v *FreeBusy) GetProperty(name string) (*Property, bool) {
+	return v.props.GetFirst(name)
+}
+
+func (v *FreeBusy) Properties() <-chan *Property {
+	return v.props.Iterator()
+}
+
+func (v *FreeBusy) AddProperty(key, value string, options ...PropertyOption) error {
+	var params Parameters
+	var values []string
+	var force bool
+	for _, option := range options {
+		switch option.Name() {
+		case "Parameters":
+			params = option.Get().(Parameters)
+		case "Values":
+			values = option.Get().([]string)
+		case "Force":
+			force = option.Get().(bool)
+		}
+	}
+
+	switch key = strings.ToLower(key); key {
+	case "dtstamp", "uid", "contact", "dtstart", "dtend", "organizer", "url":
+		v.props.Set(newProperty(key, value, params, values))
+	case "attendee", "comment", "freebusy", "request-status":
+		v.props.Append(newProperty(key, value, params, values))
+	default:
+		if strings.HasPrefix(key, "x-") || force {
+			v.props.Append(newProperty(key, value, params, values))
+		} else {
+			return errors.Errorf(`invalid property %s`, key)
+		} /* end if */
+	}
+	return nil
+}
+
+func (v *FreeBusy) MarshalJSON() ([]byte, error) {
+	var dst bytes.Buffer
+	if err := NewJSONEncoder(&dst).Encode(v); err != nil {
+		return nil, errors.Wrap(err, `failed to encode json`)
+	}
+	return dst.Bytes(), nil
+}
+
+// Validate reports the violations of RFC 5545 found in the component
+// and its sub-components as ValidationErrors
+func (v *FreeBusy) Validate() error {
+	return validateEntry(v)
+}
+
+func (v *FreeBusy) validateProperties(path string) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range []string{"dtstamp", "uid"} {
+		if _, ok := v.props.GetFirst(name); !ok {
+			errs = append(errs, newValidationError(path, name, `missing mandatory property`))
+		}
+	}
+	return errs
+}
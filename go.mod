@@ -6,4 +6,5 @@ require (
 	github.com/lestrrat-go/bufferpool v0.0.0-20180220091733-e7784e1b3e37
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.6.1
+	golang.org/x/text v0.3.7
 )
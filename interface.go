@@ -3,6 +3,8 @@ package ical
 import (
 	"io"
 	"sync"
+
+	"golang.org/x/text/encoding"
 )
 
 type Option interface {
@@ -16,6 +18,17 @@ type PropertyOption interface {
 	Get() interface{}
 }
 
+type EncoderOption interface {
+	configureEncoder(*Encoder)
+}
+
+type encoderOptionFunc func(*Encoder)
+
+type ParserOption interface {
+	Name() string
+	Get() interface{}
+}
+
 type propOptionValue struct {
 	name  string
 	value interface{}
@@ -43,14 +56,35 @@ type Property struct {
 	vcal10 bool
 	name   string
 	value  string
+	values []string
 	params Parameters
+	binary *binaryValue
 }
 
 type Parameters map[string][]string
 
-type Parser struct{}
+type parseMode int
+
+const (
+	parseModeDefault parseMode = iota
+	parseModeStrict
+	parseModeLenient
+)
+
+type Parser struct {
+	mode          parseMode
+	convertVCal10 bool
+	bare          bool
+	encoding      encoding.Encoding
+	maxLineSize   int
+	maxDepth      int
+}
 
 type Encoder struct {
-	crlf string
-	dst  io.Writer
+	crlf      string
+	dst       io.Writer
+	timezones bool
+	open      []string
+	foldWidth int
+	vcal10    bool // a vCalendar 1.0 calendar is being written
 }
@@ -23,12 +23,14 @@ func main() {
 }
 
 type definition struct {
-	Name                         string   `json:"name"`
-	Type                         string   `json:"type"`
-	MandatoryUniqueProperties    []string `json:"mandatory_unique_properties"`
-	OptionalRepeatableProperties []string `json:"optional_repeatable_properties"`
-	OptionalUniqueProperties     []string `json:"optional_unique_properties"`
-	SkipConstructor              bool     `json:"skip_constructor"`
+	Name                         string     `json:"name"`
+	Type                         string     `json:"type"`
+	ExclusiveProperties          [][]string `json:"exclusive_properties"`
+	MandatoryUniqueProperties    []string   `json:"mandatory_unique_properties"`
+	OptionalRepeatableProperties []string   `json:"optional_repeatable_properties"`
+	OptionalUniqueProperties     []string   `json:"optional_unique_properties"`
+	PairedProperties             [][]string `json:"paired_properties"`
+	SkipConstructor              bool       `json:"skip_constructor"`
 }
 
 func fieldName(s string) string {
@@ -134,11 +136,14 @@ func writeType(def *definition) error {
 
 	fmt.Fprintf(dst, "\n\nfunc (v *%s) AddProperty(key, value string, options ...PropertyOption) error {", def.Name)
 	fmt.Fprintf(dst, "\nvar params Parameters")
+	fmt.Fprintf(dst, "\nvar values []string")
 	fmt.Fprintf(dst, "\nvar force bool")
 	fmt.Fprintf(dst, "\nfor _, option := range options {")
 	fmt.Fprintf(dst, "\nswitch option.Name() {")
 	fmt.Fprintf(dst, "\ncase \"Parameters\":")
 	fmt.Fprintf(dst, "\nparams = option.Get().(Parameters)")
+	fmt.Fprintf(dst, "\ncase \"Values\":")
+	fmt.Fprintf(dst, "\nvalues = option.Get().([]string)")
 	fmt.Fprintf(dst, "\ncase \"Force\":")
 	fmt.Fprintf(dst, "\nforce = option.Get().(bool)")
 	fmt.Fprintf(dst, "\n}")
@@ -156,7 +161,7 @@ func writeType(def *definition) error {
 				fmt.Fprintf(dst, ", ")
 			}
 		}
-		fmt.Fprintf(dst, ":\nv.props.Set(NewProperty(key, value, params))")
+		fmt.Fprintf(dst, ":\nv.props.Set(newProperty(key, value, params, values))")
 	}
 
 	props = def.OptionalRepeatableProperties
@@ -168,12 +173,12 @@ func writeType(def *definition) error {
 				fmt.Fprintf(dst, ", ")
 			}
 		}
-		fmt.Fprintf(dst, ":\nv.props.Append(NewProperty(key, value, params))")
+		fmt.Fprintf(dst, ":\nv.props.Append(newProperty(key, value, params, values))")
 	}
 
 	fmt.Fprintf(dst, "\ndefault:")
 	fmt.Fprintf(dst, "\nif strings.HasPrefix(key, \"x-\") || force {")
-	fmt.Fprintf(dst, "\nv.props.Append(NewProperty(key, value, params))")
+	fmt.Fprintf(dst, "\nv.props.Append(newProperty(key, value, params, values))")
 	fmt.Fprintf(dst, "\n} else {")
 	fmt.Fprintf(dst, "\nreturn errors.Errorf(`invalid property %%s`, key)")
 	fmt.Fprintf(dst, "\n} /* end if */")
@@ -189,6 +194,45 @@ func writeType(def *definition) error {
 	fmt.Fprintf(dst, "\nreturn dst.Bytes(), nil")
 	fmt.Fprintf(dst, "\n}")
 
+	fmt.Fprintf(dst, "\n\n// Validate reports the violations of RFC 5545 found in the component")
+	fmt.Fprintf(dst, "\n// and its sub-components as ValidationErrors")
+	fmt.Fprintf(dst, "\nfunc (v *%s) Validate() error {", def.Name)
+	fmt.Fprintf(dst, "\nreturn validateEntry(v)")
+	fmt.Fprintf(dst, "\n}")
+
+	fmt.Fprintf(dst, "\n\nfunc (v *%s) validateProperties(path string) ValidationErrors {", def.Name)
+	fmt.Fprintf(dst, "\nvar errs ValidationErrors")
+	if props := def.MandatoryUniqueProperties; len(props) > 0 {
+		fmt.Fprintf(dst, "\nfor _, name := range []string{")
+		for i, prop := range props {
+			if i > 0 {
+				fmt.Fprintf(dst, ", ")
+			}
+			fmt.Fprintf(dst, "%s", strconv.Quote(prop))
+		}
+		fmt.Fprintf(dst, "} {")
+		fmt.Fprintf(dst, "\nif _, ok := v.props.GetFirst(name); !ok {")
+		fmt.Fprintf(dst, "\nerrs = append(errs, newValidationError(path, name, `missing mandatory property`))")
+		fmt.Fprintf(dst, "\n}")
+		fmt.Fprintf(dst, "\n}")
+	}
+	for _, pair := range def.ExclusiveProperties {
+		fmt.Fprintf(dst, "\nif _, ok := v.props.GetFirst(%s); ok {", strconv.Quote(pair[0]))
+		fmt.Fprintf(dst, "\nif _, ok := v.props.GetFirst(%s); ok {", strconv.Quote(pair[1]))
+		fmt.Fprintf(dst, "\nerrs = append(errs, newValidationError(path, %s, `may not be specified together with %s`))", strconv.Quote(pair[1]), pair[0])
+		fmt.Fprintf(dst, "\n}")
+		fmt.Fprintf(dst, "\n}")
+	}
+	for _, pair := range def.PairedProperties {
+		fmt.Fprintf(dst, "\n_, ok%s := v.props.GetFirst(%s)", fieldName(pair[0]), strconv.Quote(pair[0]))
+		fmt.Fprintf(dst, "\n_, ok%s := v.props.GetFirst(%s)", fieldName(pair[1]), strconv.Quote(pair[1]))
+		fmt.Fprintf(dst, "\nif ok%s != ok%s {", fieldName(pair[0]), fieldName(pair[1]))
+		fmt.Fprintf(dst, "\nerrs = append(errs, newValidationError(path, %s, `must be specified together with %s`))", strconv.Quote(pair[1]), pair[0])
+		fmt.Fprintf(dst, "\n}")
+	}
+	fmt.Fprintf(dst, "\nreturn errs")
+	fmt.Fprintf(dst, "\n}")
+
 	formatted, err := format.Source(dst.Bytes())
 	if err != nil {
 		os.Stderr.Write(dst.Bytes())
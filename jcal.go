@@ -0,0 +1,518 @@
+package ical
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// JSONEncoder encodes entries as jCal (RFC 7265)
+type JSONEncoder struct {
+	dst *json.Encoder
+}
+
+// JSONDecoder decodes jCal (RFC 7265) into entries
+type JSONDecoder struct {
+	src *json.Decoder
+}
+
+func NewJSONEncoder(dst io.Writer) *JSONEncoder {
+	return &JSONEncoder{
+		dst: json.NewEncoder(dst),
+	}
+}
+
+func (enc *JSONEncoder) Encode(e Entry) error {
+	v, err := makeJCalComponent(e)
+	if err != nil {
+		return err
+	}
+	return enc.dst.Encode(v)
+}
+
+// jcalTypes lists the value types that jCal knows of. Properties with
+// any other value type are encoded with the "unknown" type
+var jcalTypes = map[ValueType]struct{}{
+	ValueBinary:     {},
+	ValueBoolean:    {},
+	ValueCalAddress: {},
+	ValueDate:       {},
+	ValueDateTime:   {},
+	ValueDuration:   {},
+	ValueFloat:      {},
+	ValueInteger:    {},
+	ValuePeriod:     {},
+	ValueRecur:      {},
+	ValueText:       {},
+	ValueTime:       {},
+	ValueURI:        {},
+	ValueUTCOffset:  {},
+}
+
+func makeJCalComponent(e Entry) ([]interface{}, error) {
+	props := []interface{}{}
+	for p := range e.Properties() {
+		v, err := makeJCalProperty(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to encode property '%s'`, p.Name())
+		}
+		props = append(props, v)
+	}
+
+	comps := []interface{}{}
+	for sub := range e.Entries() {
+		v, err := makeJCalComponent(sub)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to encode %s`, sub.Type())
+		}
+		comps = append(comps, v)
+	}
+
+	return []interface{}{strings.ToLower(e.Type()), props, comps}, nil
+}
+
+func makeJCalProperty(p *Property) ([]interface{}, error) {
+	p, err := p.inline()
+	if err != nil {
+		return nil, err
+	}
+	params := make(map[string]interface{})
+	for k, v := range p.params {
+		if len(v) == 0 || strings.EqualFold(k, "VALUE") {
+			continue
+		}
+		if len(v) == 1 {
+			params[strings.ToLower(k)] = v[0]
+		} else {
+			params[strings.ToLower(k)] = v
+		}
+	}
+
+	vt := p.ValueType()
+	typ := strings.ToLower(string(vt))
+	if _, ok := jcalTypes[vt]; !ok {
+		typ = "unknown"
+	}
+
+	l := []interface{}{p.Name(), params, typ}
+	switch vt {
+	case ValueText:
+		switch {
+		case p.values != nil:
+			for _, v := range p.values {
+				l = append(l, v)
+			}
+		case isStructuredText(p.name):
+			var parts []interface{}
+			for _, v := range splitStructuredText(p.value) {
+				parts = append(parts, v)
+			}
+			l = append(l, parts)
+		default:
+			l = append(l, p.value)
+		}
+	case ValueDate, ValueDateTime, ValueTime:
+		for _, v := range strings.Split(p.value, ",") {
+			l = append(l, jcalDateTime(v))
+		}
+	case ValuePeriod:
+		for _, v := range strings.Split(p.value, ",") {
+			if i := strings.IndexByte(v, '/'); i > -1 {
+				end := v[i+1:]
+				if end != "" && !strings.ContainsAny(end[:1], "P+-") {
+					end = jcalDateTime(end)
+				}
+				v = jcalDateTime(v[:i]) + "/" + end
+			}
+			l = append(l, v)
+		}
+	case ValueUTCOffset:
+		l = append(l, jcalUTCOffset(p.value))
+	case ValueRecur:
+		l = append(l, jcalRecur(p.value))
+	case ValueInteger:
+		for _, v := range strings.Split(p.value, ",") {
+			if n, err := strconv.Atoi(v); err == nil {
+				l = append(l, n)
+			} else {
+				l = append(l, v)
+			}
+		}
+	case ValueFloat:
+		var floats []interface{}
+		for _, v := range strings.Split(p.value, ";") {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				floats = append(floats, f)
+			} else {
+				floats = append(floats, v)
+			}
+		}
+		if len(floats) == 1 {
+			l = append(l, floats[0])
+		} else {
+			l = append(l, floats)
+		}
+	case ValueBoolean:
+		l = append(l, strings.EqualFold(p.value, "TRUE"))
+	default:
+		l = append(l, p.value)
+	}
+	return l, nil
+}
+
+// splitStructuredText splits an escaped structured TEXT value on its
+// unescaped semicolons, and decodes each component
+func splitStructuredText(s string) []string {
+	var l []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ';':
+			l = append(l, unescapeText(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(l, unescapeText(s[start:]))
+}
+
+// jcalDateTime converts the basic format of DATE, DATE-TIME and TIME
+// values into the extended format used by jCal
+func jcalDateTime(s string) string {
+	var date, tm string
+	switch i := strings.IndexByte(s, 'T'); {
+	case i > -1:
+		date, tm = s[:i], s[i+1:]
+	case len(s) == len(dateFormat):
+		date = s
+	default:
+		tm = s
+	}
+
+	var buf strings.Builder
+	if date != "" {
+		if len(date) != 8 {
+			return s
+		}
+		buf.WriteString(date[:4] + "-" + date[4:6] + "-" + date[6:])
+		if tm != "" {
+			buf.WriteByte('T')
+		}
+	}
+	if tm != "" {
+		if len(tm) < 6 {
+			return s
+		}
+		buf.WriteString(tm[:2] + ":" + tm[2:4] + ":" + tm[4:])
+	}
+	return buf.String()
+}
+
+// jcalUTCOffset converts a UTC-OFFSET value into the extended format
+func jcalUTCOffset(v string) string {
+	if len(v) < 5 {
+		return v
+	}
+	s := v[:3] + ":" + v[3:5]
+	if len(v) == 7 {
+		s += ":" + v[5:]
+	}
+	return s
+}
+
+// icalDateTime is the inverse of jcalDateTime
+func icalDateTime(s string) string {
+	return strings.NewReplacer("-", "", ":", "").Replace(s)
+}
+
+var recurIntParts = map[string]struct{}{
+	"count":      {},
+	"interval":   {},
+	"bysecond":   {},
+	"byminute":   {},
+	"byhour":     {},
+	"bymonthday": {},
+	"byyearday":  {},
+	"byweekno":   {},
+	"bymonth":    {},
+	"bysetpos":   {},
+}
+
+var recurPartOrder = []string{"freq", "until", "count", "interval", "bysecond", "byminute", "byhour", "byday", "bymonthday", "byyearday", "byweekno", "bymonth", "bysetpos", "wkst"}
+
+func jcalRecur(s string) map[string]interface{} {
+	m := make(map[string]interface{})
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		name := strings.ToLower(kv[0])
+		var values []interface{}
+		for _, v := range strings.Split(kv[1], ",") {
+			if _, ok := recurIntParts[name]; ok {
+				if n, err := strconv.Atoi(v); err == nil {
+					values = append(values, n)
+					continue
+				}
+			}
+			if name == "until" {
+				v = jcalDateTime(v)
+			}
+			values = append(values, v)
+		}
+
+		if len(values) == 1 {
+			m[name] = values[0]
+		} else {
+			m[name] = values
+		}
+	}
+	return m
+}
+
+func NewJSONDecoder(src io.Reader) *JSONDecoder {
+	dec := json.NewDecoder(src)
+	dec.UseNumber()
+	return &JSONDecoder{
+		src: dec,
+	}
+}
+
+// Decode decodes a jCal "vcalendar" component
+func (dec *JSONDecoder) Decode() (*Calendar, error) {
+	e, err := dec.DecodeEntry()
+	if err != nil {
+		return nil, err
+	}
+
+	c, ok := e.(*Calendar)
+	if !ok {
+		return nil, errors.Errorf(`expected vcalendar, got %s`, strings.ToLower(e.Type()))
+	}
+	return c, nil
+}
+
+// DecodeEntry decodes a jCal component of any type
+func (dec *JSONDecoder) DecodeEntry() (Entry, error) {
+	var v []interface{}
+	if err := dec.src.Decode(&v); err != nil {
+		return nil, errors.Wrap(err, `failed to decode json`)
+	}
+	return decodeJCalComponent(v)
+}
+
+func decodeJCalComponent(v []interface{}) (Entry, error) {
+	if len(v) != 3 {
+		return nil, errors.Errorf(`invalid jcal component: expected 3 elements, got %d`, len(v))
+	}
+
+	name, ok := v[0].(string)
+	if !ok {
+		return nil, errors.New(`invalid jcal component: name is not a string`)
+	}
+	props, ok := v[1].([]interface{})
+	if !ok {
+		return nil, errors.Errorf(`invalid jcal component %s: properties are not an array`, name)
+	}
+	comps, ok := v[2].([]interface{})
+	if !ok {
+		return nil, errors.Errorf(`invalid jcal component %s: components are not an array`, name)
+	}
+
+	e := newEntry(name)
+	for _, prop := range props {
+		l, ok := prop.([]interface{})
+		if !ok {
+			return nil, errors.Errorf(`invalid jcal property in %s: not an array`, name)
+		}
+		if err := decodeJCalProperty(e, l); err != nil {
+			return nil, errors.Wrapf(err, `failed to decode property in %s`, name)
+		}
+	}
+
+	for _, comp := range comps {
+		l, ok := comp.([]interface{})
+		if !ok {
+			return nil, errors.Errorf(`invalid jcal component in %s: not an array`, name)
+		}
+		sub, err := decodeJCalComponent(l)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.AddEntry(sub); err != nil {
+			return nil, errors.Wrapf(err, `failed to add component to %s`, name)
+		}
+	}
+	return e, nil
+}
+
+func decodeJCalProperty(e Entry, l []interface{}) error {
+	if len(l) < 4 {
+		return errors.Errorf(`invalid jcal property: expected at least 4 elements, got %d`, len(l))
+	}
+
+	name, ok := l[0].(string)
+	if !ok {
+		return errors.New(`invalid jcal property: name is not a string`)
+	}
+	name = strings.ToLower(name)
+
+	rawParams, ok := l[1].(map[string]interface{})
+	if !ok {
+		return errors.Errorf(`invalid jcal property %s: parameters are not an object`, name)
+	}
+	params := Parameters{}
+	for k, v := range rawParams {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, pv := range v {
+				params.Add(strings.ToUpper(k), jcalString(pv))
+			}
+		default:
+			params.Add(strings.ToUpper(k), jcalString(v))
+		}
+	}
+
+	typ, ok := l[2].(string)
+	if !ok {
+		return errors.Errorf(`invalid jcal property %s: type is not a string`, name)
+	}
+	vt := ValueType(strings.ToUpper(typ))
+	if typ != "unknown" {
+		def, ok := defaultValueTypes[name]
+		if !ok {
+			def = ValueText
+		}
+		if vt != def {
+			params.Set("VALUE", string(vt))
+		}
+	}
+
+	var values []string
+	for _, v := range l[3:] {
+		s, err := decodeJCalValue(name, vt, v)
+		if err != nil {
+			return errors.Wrapf(err, `invalid value for %s`, name)
+		}
+		values = append(values, s)
+	}
+
+	if vt == ValueText && isTextList(name) {
+		return addParsedProperty(e, name, "", params, values)
+	}
+	return addParsedProperty(e, name, strings.Join(values, ","), params, nil)
+}
+
+func jcalString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return ""
+}
+
+func decodeJCalValue(name string, vt ValueType, v interface{}) (string, error) {
+	switch vt {
+	case ValueDate, ValueDateTime, ValueTime:
+		s, ok := v.(string)
+		if !ok {
+			return "", errors.Errorf(`expected string for %s`, strings.ToLower(string(vt)))
+		}
+		return icalDateTime(s), nil
+	case ValueUTCOffset:
+		s, ok := v.(string)
+		if !ok {
+			return "", errors.New(`expected string for utc-offset`)
+		}
+		return strings.Replace(s, ":", "", -1), nil
+	case ValuePeriod:
+		s, ok := v.(string)
+		if !ok {
+			return "", errors.New(`expected string for period`)
+		}
+		if i := strings.IndexByte(s, '/'); i > -1 {
+			end := s[i+1:]
+			if end != "" && !strings.ContainsAny(end[:1], "P+-") {
+				end = icalDateTime(end)
+			}
+			s = icalDateTime(s[:i]) + "/" + end
+		}
+		return s, nil
+	case ValueRecur:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", errors.New(`expected object for recur`)
+		}
+		return icalRecur(m), nil
+	}
+
+	// structured values such as GEO or REQUEST-STATUS
+	if parts, ok := v.([]interface{}); ok {
+		l := make([]string, len(parts))
+		for i, part := range parts {
+			s := jcalString(part)
+			if vt == ValueText {
+				var buf bytes.Buffer
+				escapeText(&buf, s)
+				s = buf.String()
+			}
+			l[i] = s
+		}
+		return strings.Join(l, ";"), nil
+	}
+	return jcalString(v), nil
+}
+
+func icalRecur(m map[string]interface{}) string {
+	var keys []string
+	seen := make(map[string]struct{})
+	for _, k := range recurPartOrder {
+		if _, ok := m[k]; ok {
+			keys = append(keys, k)
+			seen[k] = struct{}{}
+		}
+	}
+	var rest []string
+	for k := range m {
+		if _, ok := seen[strings.ToLower(k)]; !ok {
+			rest = append(rest, k)
+		}
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		var values []string
+		switch v := m[k].(type) {
+		case []interface{}:
+			for _, x := range v {
+				values = append(values, jcalString(x))
+			}
+		default:
+			values = append(values, jcalString(v))
+		}
+
+		s := strings.Join(values, ",")
+		if strings.EqualFold(k, "until") {
+			s = icalDateTime(s)
+		}
+		parts = append(parts, strings.ToUpper(k)+"="+s)
+	}
+	return strings.Join(parts, ";")
+}
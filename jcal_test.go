@@ -0,0 +1,151 @@
+package ical_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJCal(t *testing.T) {
+	// example from RFC 7265 appendix B.1
+	src := `["vcalendar",
+  [
+    ["calscale", {}, "text", "GREGORIAN"],
+    ["prodid", {}, "text", "-//Example Inc.//Example Calendar//EN"],
+    ["version", {}, "text", "2.0"]
+  ],
+  [
+    ["vevent",
+      [
+        ["dtstamp", {}, "date-time", "2008-02-05T19:12:24Z"],
+        ["dtstart", {}, "date", "2008-10-06"],
+        ["summary", {}, "text", "Planning meeting"],
+        ["uid", {}, "text", "4088E990AD89CB3DBB484909"]
+      ],
+      []
+    ]
+  ]
+]`
+
+	c, err := ical.NewJSONDecoder(strings.NewReader(src)).Decode()
+	if !assert.NoError(t, err, `Decode should succeed`) {
+		return
+	}
+
+	expect := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`CALSCALE:GREGORIAN`,
+		`PRODID:-//Example Inc.//Example Calendar//EN`,
+		`BEGIN:VEVENT`,
+		`DTSTAMP:20080205T191224Z`,
+		`DTSTART;VALUE=DATE:20081006`,
+		`SUMMARY:Planning meeting`,
+		`UID:4088E990AD89CB3DBB484909`,
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+	if !assert.Equal(t, expect, c.String(), `decoded calendar should match`) {
+		return
+	}
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, ical.NewJSONEncoder(&buf).Encode(c), `Encode should succeed`) {
+		return
+	}
+	if !assert.JSONEq(t, src, buf.String(), `encoded jcal should match`) {
+		return
+	}
+}
+
+func TestJCalValues(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VTIMEZONE`,
+		`TZID:America/New_York`,
+		`BEGIN:STANDARD`,
+		`DTSTART:19701101T020000`,
+		`RRULE:FREQ=YEARLY;BYDAY=1SU;BYMONTH=11`,
+		`TZOFFSETFROM:-0400`,
+		`TZOFFSETTO:-0500`,
+		`END:STANDARD`,
+		`END:VTIMEZONE`,
+		`BEGIN:VEVENT`,
+		`CATEGORIES:WORK,PROJECT\, X`,
+		`DTSTART;TZID=America/New_York:20200106T100000`,
+		`EXDATE:20200113T150000Z,20200120T150000Z`,
+		`GEO:37.386013;-122.082932`,
+		`PRIORITY:1`,
+		`REQUEST-STATUS:2.0;Success`,
+		`RRULE:FREQ=WEEKLY;UNTIL=20200301T000000Z;BYDAY=MO,WE`,
+		`X-FLAG;VALUE=BOOLEAN:TRUE`,
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, ical.NewJSONEncoder(&buf).Encode(c), `Encode should succeed`) {
+		return
+	}
+
+	for _, s := range []string{
+		`["categories",{},"text","WORK","PROJECT, X"]`,
+		`["dtstart",{"tzid":"America/New_York"},"date-time","2020-01-06T10:00:00"]`,
+		`["exdate",{},"date-time","2020-01-13T15:00:00Z","2020-01-20T15:00:00Z"]`,
+		`["geo",{},"float",[37.386013,-122.082932]]`,
+		`["priority",{},"integer",1]`,
+		`["request-status",{},"text",["2.0","Success"]]`,
+		`["rrule",{},"recur",{"byday":["MO","WE"],"freq":"WEEKLY","until":"2020-03-01T00:00:00Z"}]`,
+		`["tzoffsetto",{},"utc-offset","-05:00"]`,
+		`["x-flag",{},"boolean",true]`,
+	} {
+		if !assert.Contains(t, buf.String(), s, `jcal output should contain property`) {
+			return
+		}
+	}
+
+	c2, err := ical.NewJSONDecoder(&buf).Decode()
+	if !assert.NoError(t, err, `Decode should succeed`) {
+		return
+	}
+	if !assert.Equal(t, src, c2.String(), `round trip should match`) {
+		return
+	}
+}
+
+func TestJCalEmptyPeriodEnd(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VFREEBUSY`,
+		`UID:freebusy@example.com`,
+		`DTSTAMP:19970308T000000Z`,
+		`FREEBUSY:19970308T160000Z/`,
+		`END:VFREEBUSY`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+
+	var buf bytes.Buffer
+	if !assert.NotPanics(t, func() { ical.NewJSONEncoder(&buf).Encode(c) }, `Encode should not panic`) {
+		return
+	}
+	if !assert.Contains(t, buf.String(), `["freebusy",{},"period","1997-03-08T16:00:00Z/"]`, `jcal output should contain property`) {
+		return
+	}
+}
@@ -1,5 +1,7 @@
 package ical
 
+import "golang.org/x/text/encoding"
+
 func (f optionFunc) configure(c *Calendar) {
 	f(c)
 }
@@ -7,19 +9,23 @@ func (f optionFunc) configure(c *Calendar) {
 func WithVCal10(v bool) Option {
 	return optionFunc(func(c *Calendar) {
 		if v {
-			c.AddProperty("version", "1.0", nil)
+			c.AddProperty("version", "1.0")
 		} else {
-			c.AddProperty("version", "2.0", nil)
+			c.AddProperty("version", "2.0")
 		}
 	})
 }
 
 func WithName(s string) Option {
 	return optionFunc(func(c *Calendar) {
-		c.AddProperty("x-wr-calname", s, nil)
+		c.AddProperty("x-wr-calname", s)
 	})
 }
 
+func (f encoderOptionFunc) configureEncoder(enc *Encoder) {
+	f(enc)
+}
+
 func (p propOptionValue) Name() string {
 	return p.name
 }
@@ -35,9 +41,112 @@ func WithParameters(p Parameters) PropertyOption {
 	}
 }
 
+// WithValues specifies the list of values of a multi-valued TEXT
+// property such as CATEGORIES. Values may contain commas, which are
+// escaped when encoded
+func WithValues(l ...string) PropertyOption {
+	return propOptionValue{
+		name:  "Values",
+		value: l,
+	}
+}
+
 func WithForce(b bool) PropertyOption {
 	return propOptionValue{
 		name:  "Force",
 		value: b,
 	}
 }
+
+// WithTimezones makes the encoder add a VTIMEZONE to calendars for each
+// TZID that is referenced but not defined in the calendar. The
+// timezones are generated with NewTimezoneFromLocation, covering the
+// years of the values that reference them. TZIDs that can not be
+// resolved to a location are left without a VTIMEZONE
+func WithTimezones(b bool) EncoderOption {
+	return encoderOptionFunc(func(enc *Encoder) {
+		enc.timezones = b
+	})
+}
+
+// WithStrict makes the parser fail on any violation of RFC 5545 it
+// detects, including those it would otherwise report as warnings. Each
+// parsed calendar is also checked with Validate
+func WithStrict(b bool) ParserOption {
+	return propOptionValue{
+		name:  "Strict",
+		value: b,
+	}
+}
+
+// WithLenient makes the parser recover from structural problems such
+// as missing or misplaced END lines and malformed content lines. What
+// was repaired is returned by Parser.ParseWithWarnings
+func WithLenient(b bool) ParserOption {
+	return propOptionValue{
+		name:  "Lenient",
+		value: b,
+	}
+}
+
+// WithFoldWidth sets the maximum length of an encoded line in octets,
+// excluding the line break. Lines are folded at 75 octets by default.
+// A width of 0 disables folding
+func WithFoldWidth(n int) EncoderOption {
+	return encoderOptionFunc(func(enc *Encoder) {
+		enc.foldWidth = n
+	})
+}
+
+// WithConvertVCal10 makes the parser convert vCalendar 1.0 calendars to
+// iCalendar 2.0: recurrence rules are rewritten in the RFC 5545 grammar,
+// AALARM, DALARM and MALARM become VALARM components, and the VERSION
+// becomes 2.0. Without it, vCalendar 1.0 properties are kept as they are
+// and are written back in vCalendar 1.0 syntax
+func WithConvertVCal10(b bool) ParserOption {
+	return propOptionValue{
+		name:  "ConvertVCal10",
+		value: b,
+	}
+}
+
+// WithSourceEncoding specifies the character encoding of the input, such
+// as japanese.ShiftJIS or charmap.Windows1252, which is converted to
+// UTF-8. A byte order mark in the input takes precedence. A nil enc
+// leaves the input as it is
+func WithSourceEncoding(enc encoding.Encoding) ParserOption {
+	return propOptionValue{
+		name:  "SourceEncoding",
+		value: enc,
+	}
+}
+
+// WithMaxLineSize limits the size of a content line, after unfolding, to
+// n bytes. Longer lines make parsing fail. By default the size of lines
+// is not limited
+func WithMaxLineSize(n int) ParserOption {
+	return propOptionValue{
+		name:  "MaxLineSize",
+		value: n,
+	}
+}
+
+// WithMaxDepth limits the nesting of components to n levels, the
+// VCALENDAR being the first. Deeper components make parsing fail. By
+// default the depth is not limited
+func WithMaxDepth(n int) ParserOption {
+	return propOptionValue{
+		name:  "MaxDepth",
+		value: n,
+	}
+}
+
+// WithBareComponents makes the parser accept VEVENT and VTODO components
+// that are not enclosed in a VCALENDAR, as found in some CalDAV REPORT
+// responses. Consecutive bare components are returned as one calendar
+func WithBareComponents(b bool) ParserOption {
+	return propOptionValue{
+		name:  "BareComponents",
+		value: b,
+	}
+}
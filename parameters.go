@@ -1,13 +1,29 @@
 package ical
 
+import "strings"
+
+// Get returns the first value of the named parameter. Parameter names
+// are case-insensitive
 func (p Parameters) Get(s string) (string, bool) {
-	v, ok := p[s]
+	v, ok := p.lookup(s)
 	if ok && len(v) > 0 {
 		return v[0], true
 	}
 	return "", false
 }
 
+func (p Parameters) lookup(s string) ([]string, bool) {
+	if v, ok := p[s]; ok {
+		return v, true
+	}
+	for k, v := range p {
+		if strings.EqualFold(k, s) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
 func (p Parameters) Add(name, value string) {
 	v, ok := p[name]
 	if !ok {
@@ -16,3 +32,22 @@ func (p Parameters) Add(name, value string) {
 	v = append(v, value)
 	p[name] = v
 }
+
+// Set replaces all values of the named parameter with value
+func (p Parameters) Set(name, value string) {
+	for k := range p {
+		if strings.EqualFold(k, name) {
+			delete(p, k)
+		}
+	}
+	p[name] = []string{value}
+}
+
+// del removes all values of the named parameter
+func (p Parameters) del(name string) {
+	for k := range p {
+		if strings.EqualFold(k, name) {
+			delete(p, k)
+		}
+	}
+}
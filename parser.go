@@ -2,30 +2,174 @@ package ical
 
 import (
 	"bufio"
+	"bytes"
+	"fmt"
 	"io"
 	"os"
-	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
+	"golang.org/x/text/encoding"
 )
 
-var errUnreachable = errors.New(`can't reach here`)
+// NewParser creates a parser. By default structural errors such as a
+// missing END line abort parsing, while lines that can not be
+// interpreted are skipped. WithStrict and WithLenient change this
+func NewParser(options ...ParserOption) *Parser {
+	p := &Parser{}
+	for _, option := range options {
+		switch option.Name() {
+		case "Strict":
+			if option.Get().(bool) {
+				p.mode = parseModeStrict
+			} else if p.mode == parseModeStrict {
+				p.mode = parseModeDefault
+			}
+		case "Lenient":
+			if option.Get().(bool) {
+				p.mode = parseModeLenient
+			} else if p.mode == parseModeLenient {
+				p.mode = parseModeDefault
+			}
+		case "ConvertVCal10":
+			p.convertVCal10 = option.Get().(bool)
+		case "SourceEncoding":
+			// a nil encoding leaves the input as it is
+			p.encoding, _ = option.Get().(encoding.Encoding)
+		case "MaxLineSize":
+			p.maxLineSize = option.Get().(int)
+		case "MaxDepth":
+			p.maxDepth = option.Get().(int)
+		case "BareComponents":
+			p.bare = option.Get().(bool)
+		}
+	}
+	return p
+}
+
+// Warning describes a problem in the input that the parser recovered
+// from
+type Warning struct {
+	// Line is the 1-based number of the physical line the problem was
+	// found on
+	Line    int
+	Message string
+}
+
+func (w *Warning) String() string {
+	return fmt.Sprintf("line %d: %s", w.Line, w.Message)
+}
+
+// ParseError describes where in the input parsing failed
+type ParseError struct {
+	// StartLine and EndLine delimit the 1-based range of physical
+	// lines that make up the offending content line
+	StartLine int
+	EndLine   int
+
+	// Path locates the component being parsed in the form used by
+	// ValidationError, such as "VCALENDAR/VEVENT[3]". The index counts
+	// the preceding siblings of the same type
+	Path string
+
+	// Content is the offending (unfolded) content line. Error only
+	// includes its beginning
+	Content string
+
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	var buf strings.Builder
+	if e.StartLine == e.EndLine {
+		fmt.Fprintf(&buf, "line %d", e.StartLine)
+	} else {
+		fmt.Fprintf(&buf, "lines %d-%d", e.StartLine, e.EndLine)
+	}
+	if e.Path != "" {
+		buf.WriteString(" in ")
+		buf.WriteString(e.Path)
+	}
+	buf.WriteString(": ")
+	buf.WriteString(e.Err.Error())
+	if e.Content != "" {
+		fmt.Fprintf(&buf, " (%q)", truncateContent(e.Content))
+	}
+	return buf.String()
+}
+
+// maxErrorContent is the number of bytes of the offending content line
+// that ParseError.Error includes
+const maxErrorContent = 80
+
+// truncateContent shortens s to maxErrorContent bytes, without
+// splitting a character
+func truncateContent(s string) string {
+	if len(s) <= maxErrorContent {
+		return s
+	}
+	n := maxErrorContent
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "\u2026"
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// parsedLine is a physical line along with its line number
+type parsedLine struct {
+	text string
+	line int
+}
 
-func NewParser() *Parser {
-	return &Parser{}
+// position is the range of physical lines of a content line
+type position struct {
+	start   int
+	end     int
+	content string
 }
 
-type container interface {
-	AddEntry(Entry) error
+// parseFrame is a component that is being parsed
+type parseFrame struct {
+	name  string
+	index int
+	entry Entry
+
+	// children counts the sub-components by type, and props counts the
+	// properties read so far by name, used to detect unique properties
+	// that are given more than once
+	children map[string]int
+	props    map[string]int
 }
 
 type parseCtx struct {
-	calendar *Calendar
-	current  []string
-	parent   container
+	current  []*parseFrame
 	scanner  *bufio.Scanner
-	readbuf  []string
+	readbuf  []parsedLine
+	mode     parseMode
+	warnings []*Warning
+	lineno   int // number of physical lines read so far
+	line     int // line number of the last line returned by next
+	pos      position
+	vcal10   bool // a vCalendar 1.0 calendar is being parsed
+	convert  bool // convert vCalendar 1.0 to iCalendar 2.0
+	bare     bool // accept top-level VEVENT and VTODO components
+
+	// charsetKnown is set if the encoding of the input was given or
+	// detected, in which case it has been converted to UTF-8 as a whole
+	charsetKnown bool
+
+	maxLineSize int // maximum size of a content line in bytes, if > 0
+	maxDepth    int // maximum nesting depth of components, if > 0
+
+	// eol is the line terminator of the last line scanned when it is
+	// not CRLF, and warnedEOL is set once that has been warned about
+	eol       string
+	warnedEOL bool
 }
 
 func (p *Parser) ParseFile(filename string) (*Calendar, error) {
@@ -38,36 +182,278 @@ func (p *Parser) ParseFile(filename string) (*Calendar, error) {
 	return p.Parse(f)
 }
 
-var childEntries = map[string][]string{
-	"VCALENDAR": []string{"VTIMEZONE", "VEVENT"},
-	"VTIMEZONE": []string{"DAYLIGHT", "STANDARD"},
+// Parse reads the first calendar from src. Blank lines and other lines
+// before it are skipped
+func (p *Parser) Parse(src io.Reader) (*Calendar, error) {
+	c, _, err := p.ParseWithWarnings(src)
+	return c, err
 }
 
-func (p *Parser) Parse(src io.Reader) (*Calendar, error) {
+// ParseWithWarnings is like Parse, but also returns the problems in the
+// input that were recovered from
+func (p *Parser) ParseWithWarnings(src io.Reader) (*Calendar, []*Warning, error) {
+	var ctx parseCtx
+	ctx.init(src, p)
+
+	c, err := ctx.nextCalendar()
+	if err != nil {
+		if err == io.EOF {
+			err = errors.New(`no calendar found`)
+		}
+		return nil, ctx.warnings, errors.Wrap(err, `failed to parse ical`)
+	}
+	return c, ctx.warnings, nil
+}
+
+// ParseAll reads all calendars from src, such as the concatenated
+// calendars of a mail body. Lines between the calendars are skipped
+func (p *Parser) ParseAll(src io.Reader) ([]*Calendar, error) {
+	l, _, err := p.ParseAllWithWarnings(src)
+	return l, err
+}
+
+// ParseAllWithWarnings is like ParseAll, but also returns the problems
+// in the input that were recovered from
+func (p *Parser) ParseAllWithWarnings(src io.Reader) ([]*Calendar, []*Warning, error) {
 	var ctx parseCtx
+	ctx.init(src, p)
+
+	var l []*Calendar
+	for {
+		c, err := ctx.nextCalendar()
+		if err == io.EOF {
+			return l, ctx.warnings, nil
+		}
+		if err != nil {
+			return nil, ctx.warnings, errors.Wrapf(err, `failed to parse calendar #%d`, len(l)+1)
+		}
+		l = append(l, c)
+	}
+}
+
+// nextCalendar reads the next calendar. With WithBareComponents, a run
+// of top-level VEVENT and VTODO components is returned as a calendar of
+// its own
+func (ctx *parseCtx) nextCalendar() (*Calendar, error) {
+	var bare *Calendar
+	for {
+		name, err := ctx.seek(bare == nil)
+		if err != nil {
+			if err == io.EOF && bare != nil {
+				return bare, nil
+			}
+			return nil, err
+		}
+
+		switch {
+		case name == "" || (name == "VCALENDAR" && bare != nil):
+			return bare, nil
+		case name == "VCALENDAR":
+			v, err := ctx.parse(name)
+			if err != nil {
+				return nil, err
+			}
+			if err := ctx.validate(v); err != nil {
+				return nil, err
+			}
+			return v.(*Calendar), nil
+		}
+
+		if bare == nil {
+			bare = New()
+		}
+		v, err := ctx.parse(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.validate(v); err != nil {
+			return nil, err
+		}
+		if err := bare.AddEntry(v); err != nil {
+			return nil, ctx.wrap(errors.Wrapf(err, `failed to add %s`, name))
+		}
+	}
+}
 
+// seek skips to the BEGIN line of the next top-level component, and
+// returns the name of the component. Blank lines are skipped silently,
+// other lines with a warning. If skipJunk is false, an empty name is
+// returned for the first line that is neither, which ends a run of bare
+// components
+func (ctx *parseCtx) seek(skipJunk bool) (string, error) {
+	for {
+		l, err := ctx.peek()
+		if err != nil {
+			return "", err
+		}
+		if name := strings.TrimPrefix(l, "BEGIN:"); name != l {
+			switch name {
+			case "VCALENDAR":
+				return name, nil
+			case "VEVENT", "VTODO":
+				if ctx.bare {
+					return name, nil
+				}
+			}
+		}
+
+		if strings.TrimSpace(l) == "" {
+			ctx.next()
+			continue
+		}
+		if !skipJunk {
+			return "", nil
+		}
+		ctx.next()
+		if err := ctx.warn(`skipped line outside of a calendar`); err != nil {
+			return "", err
+		}
+	}
+}
+
+// init prepares ctx for reading src with the settings of p
+func (ctx *parseCtx) init(src io.Reader, p *Parser) {
+	ctx.mode = p.mode
+	ctx.convert = p.convertVCal10
+	ctx.bare = p.bare
+	src, ctx.charsetKnown = sourceReader(src, p.encoding)
+	ctx.maxLineSize = p.maxLineSize
+	ctx.maxDepth = p.maxDepth
 	ctx.scanner = bufio.NewScanner(src)
-	if err := ctx.parse("VCALENDAR"); err != nil {
-		return nil, errors.Wrap(err, `failed to parse ical`)
+	ctx.scanner.Split(ctx.scanLines)
+
+	// the buffer grows as needed. Physical lines can not be longer
+	// than the content lines they are part of, plus the line break
+	max := int(^uint(0) >> 1)
+	if ctx.maxLineSize > 0 {
+		max = ctx.maxLineSize + 2
+	}
+	ctx.scanner.Buffer(make([]byte, 4096), max)
+}
+
+// scanLines splits the input into physical lines. Besides CRLF, lines
+// may be terminated by a bare LF or CR, which is recorded in ctx.eol
+func (ctx *parseCtx) scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			ctx.eol = "LF"
+			return i + 1, data[:i], nil
+		}
+		switch {
+		case i+1 < len(data) && data[i+1] == '\n':
+			ctx.eol = ""
+			return i + 2, data[:i], nil
+		case i+1 < len(data):
+			ctx.eol = "CR"
+			return i + 1, data[:i], nil
+		case atEOF:
+			// a CR at the very end is taken as a truncated CRLF
+			ctx.eol = ""
+			return i + 1, data[:i], nil
+		}
+		// need more data to tell CR from CRLF
+		return 0, nil, nil
+	}
+	if atEOF {
+		ctx.eol = ""
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// path describes the components being parsed, for use in errors
+func (ctx *parseCtx) path() string {
+	var path string
+	for _, f := range ctx.current {
+		path = componentPath(path, f.name, f.index)
 	}
-	return ctx.calendar, nil
+	return path
+}
+
+// errorf creates a ParseError for the current position
+func (ctx *parseCtx) errorf(format string, args ...interface{}) error {
+	return ctx.wrap(errors.Errorf(format, args...))
+}
+
+// wrap makes err a ParseError for the current position
+func (ctx *parseCtx) wrap(err error) error {
+	return &ParseError{
+		StartLine: ctx.pos.start,
+		EndLine:   ctx.pos.end,
+		Path:      ctx.path(),
+		Content:   ctx.pos.content,
+		Err:       err,
+	}
+}
+
+// warn records a problem at the current position that the parser can
+// work around. In strict mode it is reported as an error instead
+func (ctx *parseCtx) warn(format string, args ...interface{}) error {
+	if ctx.mode == parseModeStrict {
+		return ctx.errorf(format, args...)
+	}
+	ctx.warnings = append(ctx.warnings, &Warning{Line: ctx.pos.start, Message: fmt.Sprintf(format, args...)})
+	return nil
+}
+
+// validate checks e against RFC 5545 in strict mode
+func (ctx *parseCtx) validate(e Entry) error {
+	if ctx.mode != parseModeStrict {
+		return nil
+	}
+	if err := validateEntry(e); err != nil {
+		return errors.Wrapf(err, `invalid %s`, e.Type())
+	}
+	return nil
+}
+
+// repair records a problem at the current position that the parser only
+// works around in lenient mode. In other modes it is reported as an error
+func (ctx *parseCtx) repair(format string, args ...interface{}) error {
+	if ctx.mode != parseModeLenient {
+		return ctx.errorf(format, args...)
+	}
+	return ctx.warn(format, args...)
 }
 
 func (ctx *parseCtx) next() (ret string, err error) {
 	if len(ctx.readbuf) > 0 {
 		l := ctx.readbuf[len(ctx.readbuf)-1]
 		ctx.readbuf = ctx.readbuf[:len(ctx.readbuf)-1]
-		return l, nil
+		ctx.line = l.line
+		ctx.pos = position{start: l.line, end: l.line, content: l.text}
+		return l.text, nil
 	}
 
 	if !ctx.scanner.Scan() {
-		return "", io.EOF
+		switch err := ctx.scanner.Err(); err {
+		case nil:
+			return "", io.EOF
+		case bufio.ErrTooLong:
+			ctx.pos = position{start: ctx.lineno + 1, end: ctx.lineno + 1}
+			return "", ctx.errorf(`line exceeds the maximum size of %d bytes`, ctx.maxLineSize)
+		default:
+			return "", errors.Wrap(err, `failed to read line`)
+		}
+	}
+	ctx.lineno++
+	ctx.line = ctx.lineno
+	ctx.pos = position{start: ctx.line, end: ctx.line, content: ctx.scanner.Text()}
+
+	if ctx.eol != "" && !ctx.warnedEOL {
+		ctx.warnedEOL = true
+		if err := ctx.warn(`line is terminated by %s instead of CRLF`, ctx.eol); err != nil {
+			return "", err
+		}
 	}
 	return ctx.scanner.Text(), nil
 }
 
 func (ctx *parseCtx) pushback(l string) {
-	ctx.readbuf = append(ctx.readbuf, l)
+	ctx.readbuf = append(ctx.readbuf, parsedLine{text: l, line: ctx.line})
 }
 
 func (ctx *parseCtx) peek() (string, error) {
@@ -79,172 +465,398 @@ func (ctx *parseCtx) peek() (string, error) {
 	return l, nil
 }
 
-var looksLikePropertyRe = regexp.MustCompile(`^[^:]+:.*$`)
-
-func (ctx *parseCtx) nextProperty() (string, string, Parameters, error) {
+// nextProperty reads the next content line, unfolding the physical lines
+// that start with a space or a tab into it. The unfolding works on bytes,
+// so that a multi-byte character split across lines is restored intact.
+// In vCalendar 1.0 the whitespace is part of the value, and
+// QUOTED-PRINTABLE values continue after soft line breaks ('=' at the
+// end of the line) as well
+func (ctx *parseCtx) nextProperty() (string, Parameters, string, error) {
 	l, err := ctx.next()
 	if err != nil {
-		return "", "", nil, errors.Wrap(err, `failed to fetch line`)
+		return "", nil, "", errors.Wrap(err, `failed to fetch line`)
 	}
-	
+
 	//add support (skip) empty lines
-	if len(l) == 0 || !strings.Contains(l, ":") {
-		return "", "", nil, nil
+	if len(l) == 0 {
+		return "", nil, "", ctx.warn(`skipped empty line`)
 	}
-	
-	pair := strings.SplitN(l, ":", 2)
-	n, val := pair[0], pair[1]
+	start, end := ctx.line, ctx.line
+
+	// lines are collected in a byte slice, as values such as inline
+	// attachments may be folded over many thousands of lines
+	buf := []byte(l)
+	qp := ctx.vcal10 && isQuotedPrintableLine(l)
 	for {
+		if ctx.maxLineSize > 0 && len(buf) > ctx.maxLineSize {
+			ctx.pos = position{start: start, end: end}
+			return "", nil, "", ctx.errorf(`content line exceeds the maximum size of %d bytes`, ctx.maxLineSize)
+		}
+
+		softbreak := qp && strings.HasSuffix(l, "=")
 		l, err = ctx.peek()
 		if err != nil {
-			break // EOF? oh well
+			if err == io.EOF {
+				break
+			}
+			return "", nil, "", err
 		}
-		if looksLikePropertyRe.MatchString(l) {
+		if softbreak {
+			buf = append(buf[:len(buf)-1], l...)
+		} else if !isContinuation(l) {
 			break
+		} else if ctx.vcal10 {
+			buf = append(buf, l...)
+		} else {
+			buf = append(buf, l[1:]...)
 		}
 		ctx.next()
-		// Remove first space
-		
-		if len(l) > 1 {
-			val += l[1:]
-		}
+		end = ctx.line
 	}
+	line := string(buf)
 
-	// name may contain parameters
-	var params = Parameters{}
-	paramslist := strings.Split(n, ";")
-	for i, v := range paramslist {
-		if i == 0 {
-			continue
+	if !strings.Contains(line, ":") {
+		ctx.pos = position{start: start, end: end, content: line}
+		return "", nil, "", ctx.warn(`skipped line without ':'`)
+	}
+
+	ctx.pos = position{start: start, end: end, content: line}
+	name, params, value, err := parseContentLine(line, ctx.vcal10)
+	if err != nil {
+		if err := ctx.repair(`malformed content line: %s`, err); err != nil {
+			return "", nil, "", err
 		}
-		ppair := strings.SplitN(v, "=", 2)
-		params.Add(ppair[0], ppair[1])
+		return "", nil, "", nil
 	}
+	return name, params, value, nil
+}
 
-	return paramslist[0], strings.Replace(val, "\\", "", -1), params, nil
+// isContinuation reports whether the physical line l continues the
+// preceding content line (RFC 5545 section 3.1)
+func isContinuation(l string) bool {
+	return len(l) > 0 && (l[0] == ' ' || l[0] == '\t')
 }
 
-func (ctx *parseCtx) handlerFor(name string) func() error {
-	switch name {
-	case "VTIMEZONE":
-		return ctx.parseTimezone
-	case "VEVENT":
-		return ctx.parseEvent
-	case "DAYLIGHT":
-		return ctx.parseDaylight
-	case "STANDARD":
-		return ctx.parseStandard
+// decodeValue decodes the raw value of a property. Only TEXT values are
+// escaped, and lists of TEXT values are split into their elements
+func decodeValue(name, val string, params Parameters) (string, []string) {
+	p := Property{name: strings.ToLower(name), params: params}
+	if p.ValueType() != ValueText || isStructuredText(p.name) {
+		return val, nil
 	}
-	return func() error { return nil }
+	if isTextList(p.name) {
+		l := splitText(val)
+		return strings.Join(l, ","), l
+	}
+	return unescapeText(val), nil
 }
 
-func (ctx *parseCtx) entryFor(name string) Entry {
-	switch name {
+// addParsedProperty adds a property read from an external source to e.
+// Properties that are not defined for the component (such as IANA
+// properties registered after RFC 5545) are retained as is, so that no
+// data is lost
+func addParsedProperty(e Entry, name, value string, params Parameters, values []string) error {
+	options := []PropertyOption{WithParameters(params), WithValues(values...)}
+	if err := e.AddProperty(name, value, options...); err != nil {
+		if err := e.AddProperty(name, value, append(options, WithForce(true))...); err != nil {
+			return errors.Wrapf(err, `failed to add property %s`, name)
+		}
+	}
+	return nil
+}
+
+// newEntry creates an empty component of the given type. Components
+// that have no dedicated type are created as generic components
+func newEntry(name string) Entry {
+	switch strings.ToUpper(name) {
 	case "VCALENDAR":
-		ctx.calendar = New()
-		return ctx.calendar
+		return NewCalendar()
 	case "VTIMEZONE":
 		return NewTimezone()
 	case "VEVENT":
 		return NewEvent()
+	case "VTODO":
+		return NewTodo()
+	case "VJOURNAL":
+		return NewJournal()
+	case "VFREEBUSY":
+		return NewFreeBusy()
+	case "VALARM":
+		return NewAlarm()
 	case "DAYLIGHT":
 		return NewDaylight()
 	case "STANDARD":
 		return NewStandard()
 	}
-	return nil
+	return NewComponent(name)
 }
 
-func (ctx *parseCtx) parse(name string) error {
-	children := childEntries[name]
-	finalize, check, err := ctx.begin(name)
+// parse reads a complete component, including its sub-components
+func (ctx *parseCtx) parse(name string) (Entry, error) {
+	v, err := ctx.open(name)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer ctx.close()
 
-	v := ctx.entryFor(name)
-	if v == nil {
-		return errors.Errorf(`could not create entry for %s`, name)
-	}
-OUTER:
 	for {
-		l, err := ctx.peek()
+		child, err := ctx.nextItem()
 		if err != nil {
-			return errors.Wrap(err, `failed to peek`)
+			return nil, err
+		}
+		if child == nil {
+			return v, nil
 		}
+		if err := v.AddEntry(child); err != nil {
+			return nil, ctx.wrap(errors.Wrapf(err, `failed to add %s`, child.Type()))
+		}
+	}
+}
 
-		for _, chld := range children {
-			if l != "BEGIN:"+chld {
-				continue
-			}
+// open reads the BEGIN line of a component, and makes it the component
+// being parsed
+func (ctx *parseCtx) open(name string) (Entry, error) {
+	if err := ctx.begin(name); err != nil {
+		return nil, err
+	}
+	if ctx.maxDepth > 0 && len(ctx.current) >= ctx.maxDepth {
+		return nil, ctx.errorf(`components are nested deeper than %d levels`, ctx.maxDepth)
+	}
+	if n := len(ctx.current); n > 0 {
+		if err := ctx.checkNesting(name, ctx.current[n-1].name); err != nil {
+			return nil, err
+		}
+	}
 
-			h := ctx.handlerFor(chld)
-			oldp := ctx.parent
-			ctx.parent = v
-			if err := h(); err != nil {
-				return errors.Wrapf(err, `failed to parse %s`, chld)
-			}
-			ctx.parent = oldp
-			continue OUTER
+	frame := &parseFrame{
+		name:     name,
+		entry:    newEntry(name),
+		children: make(map[string]int),
+		props:    make(map[string]int),
+	}
+	if n := len(ctx.current); n > 0 {
+		siblings := ctx.current[n-1].children
+		frame.index = siblings[name]
+		siblings[name]++
+	}
+	ctx.current = append(ctx.current, frame)
+
+	if len(ctx.current) == 1 && name == "VCALENDAR" {
+		if err := ctx.detectVersion(); err != nil {
+			return nil, err
 		}
+	}
+	return frame.entry, nil
+}
 
-		if check(l) {
-			if ctx.parent != nil {
-				ctx.parent.AddEntry(v)
+// detectVersion looks ahead for the VERSION of the calendar that was
+// just begun, so that the properties before it are read with the rules
+// of vCalendar 1.0 as well, if that is the version. The lines up to the
+// first sub-component are read, and pushed back
+func (ctx *parseCtx) detectVersion() error {
+	ctx.vcal10 = false
+
+	var lines []parsedLine
+	defer func() {
+		for i := len(lines) - 1; i >= 0; i-- {
+			ctx.readbuf = append(ctx.readbuf, lines[i])
+		}
+	}()
+	for {
+		l, err := ctx.next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
 			}
-			return finalize()
+			return err
 		}
+		lines = append(lines, parsedLine{text: l, line: ctx.line})
 
-		n, val, params, err := ctx.nextProperty()
-		if err != nil {
-			return errors.Wrap(err, `failed to read next property`)
+		name := strings.ToUpper(l)
+		if i := strings.IndexAny(name, ";:"); i > -1 {
+			name = name[:i]
+		}
+		switch name {
+		case "BEGIN", "END":
+			return nil
+		case "VERSION":
+			if i := strings.IndexByte(l, ':'); i > -1 && strings.TrimSpace(l[i+1:]) == "1.0" {
+				ctx.vcal10 = true
+			}
+			return nil
 		}
-		v.AddProperty(n, val, WithParameters(params))
 	}
+}
 
-	return errUnreachable
+// componentParents lists the components that each of the components
+// defined by RFC 5545 may be nested in. Other components may appear
+// anywhere
+var componentParents = map[string][]string{
+	"VCALENDAR": nil,
+	"VEVENT":    {"VCALENDAR"},
+	"VTODO":     {"VCALENDAR"},
+	"VJOURNAL":  {"VCALENDAR"},
+	"VFREEBUSY": {"VCALENDAR"},
+	"VTIMEZONE": {"VCALENDAR"},
+	"STANDARD":  {"VTIMEZONE"},
+	"DAYLIGHT":  {"VTIMEZONE"},
+	"VALARM":    {"VEVENT", "VTODO"},
 }
 
-func (ctx *parseCtx) begin(name string) (func() error, func(string) bool, error) {
-	l, err := ctx.next()
-	if err != nil {
-		return nil, nil, errors.Wrap(err, `failed to fetch next line`)
+// checkNesting reports a component name that is nested in a component
+// it is not allowed in. The component is retained all the same, unless
+// in strict mode
+func (ctx *parseCtx) checkNesting(name, parent string) error {
+	allowed, ok := componentParents[name]
+	if !ok {
+		return nil
 	}
-	if l != "BEGIN:"+name {
-		return nil, nil, errors.Errorf(`expected BEGIN:%s, got %s`, name, l)
+	for _, v := range allowed {
+		if v == parent {
+			return nil
+		}
 	}
+	return ctx.warn(`%s is not allowed in %s`, name, parent)
+}
 
-	end := "END:" + name
-	finalizer := func() error {
-		l, err := ctx.next()
+// close ends the component being parsed
+func (ctx *parseCtx) close() {
+	ctx.current = ctx.current[:len(ctx.current)-1]
+}
+
+// nextItem reads the properties of the component being parsed until
+// either a sub-component has been read completely, which is returned,
+// or the component ends, in which case nil is returned
+func (ctx *parseCtx) nextItem() (Entry, error) {
+	frame := ctx.current[len(ctx.current)-1]
+	v, name := frame.entry, frame.name
+	for {
+		l, err := ctx.peek()
 		if err != nil {
-			return errors.Wrap(err, `failed to fetch next line`)
+			if err != io.EOF {
+				return nil, errors.Wrap(err, `failed to peek`)
+			}
+			ctx.pos = position{start: ctx.lineno, end: ctx.lineno}
+			if err := ctx.repair(`missing END:%s at end of input`, name); err != nil {
+				return nil, err
+			}
+			return nil, nil
 		}
 
-		if l != end {
-			return errors.Errorf(`expected %s, got %s`, end, l)
+		// nested components. Components that have no dedicated type
+		// are retained as generic components
+		if strings.HasPrefix(l, "BEGIN:") {
+			return ctx.parse(strings.TrimPrefix(l, "BEGIN:"))
+		}
+
+		if strings.HasPrefix(l, "END:") {
+			end := strings.TrimPrefix(l, "END:")
+			if end == name {
+				ctx.next()
+				return nil, nil
+			}
+
+			// an END for an enclosing component closes this one as
+			// well. Any other END line is dropped
+			if ctx.isOpen(end) {
+				if err := ctx.repair(`missing END:%s`, name); err != nil {
+					return nil, err
+				}
+				return nil, nil
+			}
+			ctx.next()
+			if err := ctx.repair(`expected END:%s, got %s`, name, l); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		n, params, val, err := ctx.nextProperty()
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to read next property`)
+		}
+		if n == "" {
+			continue
+		}
+
+		// a unique property replaces the first one of its name, while
+		// repeatable ones are appended
+		key := strings.ToLower(n)
+		first, _ := v.GetProperty(key)
+		if err := ctx.addProperty(v, key, val, params); err != nil {
+			return nil, err
+		}
+		frame.props[key]++
+		if p, _ := v.GetProperty(key); frame.props[key] > 1 && first != nil && p != first {
+			if err := ctx.warn(`duplicate %s property in %s, keeping the last one`, strings.ToUpper(n), name); err != nil {
+				return nil, err
+			}
 		}
-		return nil
 	}
-	checker := func(s string) bool {
-		return end == s
+}
+
+// addProperty decodes a property read from the input to UTF-8, and adds
+// it to v. Properties of vCalendar 1.0 calendars are marked as such, or
+// converted to iCalendar 2.0 if requested
+func (ctx *parseCtx) addProperty(v Entry, name, val string, params Parameters) error {
+	if len(ctx.current) == 1 && name == "version" && strings.TrimSpace(val) == "1.0" {
+		ctx.vcal10 = true
 	}
 
-	return finalizer, checker, nil
-}
+	var encoded bool
+	if enc, ok := params.Get("ENCODING"); ok && ctx.vcal10 && strings.EqualFold(enc, "QUOTED-PRINTABLE") {
+		val = string(decodeQuotedPrintable(val))
+		params.del("ENCODING")
+		encoded = true
+	}
 
-func (ctx *parseCtx) parseTimezone() error {
-	return ctx.parse("VTIMEZONE")
-}
+	val, err := ctx.normalizeCharset(name, val, params, encoded)
+	if err != nil {
+		return err
+	}
 
-func (ctx *parseCtx) parseDaylight() error {
-	return ctx.parse("DAYLIGHT")
+	if !ctx.vcal10 {
+		val, values := decodeValue(name, val, params)
+		if err := addParsedProperty(v, name, val, params, values); err != nil {
+			return ctx.wrap(err)
+		}
+		return nil
+	}
+
+	if ctx.convert {
+		if err := convertVCal10Property(v, name, val, params); err != nil {
+			if err := ctx.repair(`%s`, err); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := addParsedProperty(v, name, val, params, nil); err != nil {
+		return ctx.wrap(err)
+	}
+	l := entryProperties(v, name)
+	l[len(l)-1].vcal10 = true
+	return nil
 }
 
-func (ctx *parseCtx) parseStandard() error {
-	return ctx.parse("STANDARD")
+// isOpen reports if a component named name is being parsed
+func (ctx *parseCtx) isOpen(name string) bool {
+	for _, f := range ctx.current {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
 }
 
-func (ctx *parseCtx) parseEvent() error {
-	return ctx.parse("VEVENT")
+func (ctx *parseCtx) begin(name string) error {
+	l, err := ctx.next()
+	if err != nil {
+		return errors.Wrap(err, `failed to fetch next line`)
+	}
+	if l != "BEGIN:"+name {
+		return ctx.errorf(`expected BEGIN:%s`, name)
+	}
+	return nil
 }
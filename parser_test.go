@@ -0,0 +1,785 @@
+package ical_test
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseText(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VEVENT`,
+		`UID:text@example.com`,
+		`DESCRIPTION:line one\nline two\Nline three\, with a comma\; and a back\\slash`,
+		`URL:http://example.com/a\,b`,
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+
+	var ev *ical.Event
+	for e := range c.Entries() {
+		ev = e.(*ical.Event)
+	}
+	if !assert.NotNil(t, ev, `event should be parsed`) {
+		return
+	}
+
+	expect := map[string]string{
+		"description": "line one\nline two\nline three, with a comma; and a back\\slash",
+		"url":         `http://example.com/a\,b`,
+	}
+	for name, value := range expect {
+		p, ok := ev.GetProperty(name)
+		if !assert.True(t, ok, `property %s should exist`, name) {
+			return
+		}
+		if !assert.Equal(t, value, p.RawValue(), `value of %s should match`, name) {
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, ical.NewEncoder(&buf).Encode(ev), `Encode should succeed`) {
+		return
+	}
+	for _, l := range []string{
+		`DESCRIPTION:line one\nline two\nline three\, with a comma\; and a back\\sla`,
+		`URL:http://example.com/a\,b`,
+	} {
+		if !assert.Contains(t, buf.String(), l+"\r\n", `encoded value should match`) {
+			return
+		}
+	}
+}
+
+func TestTextList(t *testing.T) {
+	todo := ical.NewTodo()
+	todo.AddProperty("categories", "", ical.WithValues("work, urgent", "home"))
+
+	p, ok := todo.GetProperty("categories")
+	if !assert.True(t, ok, `property should exist`) {
+		return
+	}
+	if !assert.Equal(t, []string{"work, urgent", "home"}, p.Values(), `values should match`) {
+		return
+	}
+
+	expect := "BEGIN:VTODO\r\nCATEGORIES:work\\, urgent,home\r\nEND:VTODO\r\n"
+	if !assert.Equal(t, expect, todo.String(), `encoded list should match`) {
+		return
+	}
+}
+
+func TestParseParameters(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VEVENT`,
+		`UID:params@example.com`,
+		`ORGANIZER;CN="Doe; John";SENT-BY="mailto:a@example.com":mailto:jdoe@example.com`,
+		`LOCATION;X-ADDR=^'Main St.^'^n12345 ^^ Town:Office`,
+		`DESCRIPTION;X-LIST=a,"b,c",d:list`,
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+
+	var ev *ical.Event
+	for e := range c.Entries() {
+		ev = e.(*ical.Event)
+	}
+	if !assert.NotNil(t, ev, `event should be parsed`) {
+		return
+	}
+
+	p, _ := ev.GetProperty("organizer")
+	if !assert.Equal(t, "mailto:jdoe@example.com", p.RawValue(), `value should match`) {
+		return
+	}
+	if v, _ := p.Parameters().Get("CN"); !assert.Equal(t, "Doe; John", v, `quoted value should match`) {
+		return
+	}
+	if v, _ := p.Parameters().Get("SENT-BY"); !assert.Equal(t, "mailto:a@example.com", v, `quoted value should match`) {
+		return
+	}
+
+	p, _ = ev.GetProperty("location")
+	if v, _ := p.Parameters().Get("X-ADDR"); !assert.Equal(t, "\"Main St.\"\n12345 ^ Town", v, `caret escapes should be decoded`) {
+		return
+	}
+
+	p, _ = ev.GetProperty("description")
+	if !assert.Equal(t, []string{"a", "b,c", "d"}, p.Parameters()["X-LIST"], `multiple values should be split`) {
+		return
+	}
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, ical.NewEncoder(&buf).Encode(ev), `Encode should succeed`) {
+		return
+	}
+	c2, err := ical.NewParser().Parse(strings.NewReader("BEGIN:VCALENDAR\r\n" + buf.String() + "END:VCALENDAR\r\n"))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+	for e := range c2.Entries() {
+		if !assert.Equal(t, ev, e, `round trip should preserve parameters`) {
+			return
+		}
+	}
+
+	for _, l := range []string{`SUMMARY;LANGUAGE:foo`, `SUMMARY;CN="foo:bar`, `;CN=x:foo`} {
+		_, err := ical.NewParser().Parse(strings.NewReader("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\n" + l + "\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"))
+		if !assert.Error(t, err, `Parse should fail for %s`, l) {
+			return
+		}
+	}
+}
+
+func TestParseComponents(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VEVENT`,
+		`SUMMARY:Meeting`,
+		`UID:event@example.com`,
+		`BEGIN:VALARM`,
+		`ACTION:DISPLAY`,
+		`DESCRIPTION:Reminder`,
+		`TRIGGER:-PT15M`,
+		`END:VALARM`,
+		`END:VEVENT`,
+		`BEGIN:VTODO`,
+		`SUMMARY:Submit report`,
+		`UID:todo@example.com`,
+		`BEGIN:VALARM`,
+		`ACTION:AUDIO`,
+		`TRIGGER;VALUE=DATE-TIME:19980403T120000Z`,
+		`END:VALARM`,
+		`END:VTODO`,
+		`BEGIN:VJOURNAL`,
+		`DESCRIPTION:First entry`,
+		`DESCRIPTION:Second entry`,
+		`UID:journal@example.com`,
+		`END:VJOURNAL`,
+		`BEGIN:VFREEBUSY`,
+		`FREEBUSY:19970308T160000Z/PT8H30M`,
+		`FREEBUSY;FBTYPE=BUSY:19970308T230000Z/19970309T000000Z`,
+		`UID:freebusy@example.com`,
+		`END:VFREEBUSY`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+
+	var types []string
+	for e := range c.Entries() {
+		types = append(types, e.Type())
+		switch e := e.(type) {
+		case *ical.Event, *ical.Todo:
+			var alarms int
+			for sub := range e.Entries() {
+				if !assert.IsType(t, &ical.Alarm{}, sub, `child should be an alarm`) {
+					return
+				}
+				if _, ok := sub.GetProperty("trigger"); !assert.True(t, ok, `alarm should have a trigger`) {
+					return
+				}
+				alarms++
+			}
+			if !assert.Equal(t, 1, alarms, `there should be 1 alarm`) {
+				return
+			}
+			if _, ok := e.GetProperty("trigger"); !assert.False(t, ok, `alarm properties should not leak into %s`, e.Type()) {
+				return
+			}
+		case *ical.Journal:
+			if !assert.Len(t, entryProps(e, "description"), 2, `journal should have 2 descriptions`) {
+				return
+			}
+		case *ical.FreeBusy:
+			if !assert.Len(t, entryProps(e, "freebusy"), 2, `freebusy should have 2 periods`) {
+				return
+			}
+		}
+	}
+	if !assert.Equal(t, []string{"VEVENT", "VTODO", "VJOURNAL", "VFREEBUSY"}, types, `component types should match`) {
+		return
+	}
+
+	if !assert.Equal(t, src, c.String(), `round trip should match`) {
+		return
+	}
+
+	// RFC 5545 does not define DURATION for VFREEBUSY
+	if !assert.Error(t, ical.NewFreeBusy().AddProperty("duration", "PT1H"), `DURATION should be rejected in VFREEBUSY`) {
+		return
+	}
+}
+
+func entryProps(e ical.Entry, name string) []*ical.Property {
+	var l []*ical.Property
+	for p := range e.Properties() {
+		if p.Name() == name {
+			l = append(l, p)
+		}
+	}
+	return l
+}
+
+func TestParseUnknownComponents(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VEVENT`,
+		`UID:event@example.com`,
+		`BEGIN:X-VENDOR-DATA`,
+		`X-KEY:one`,
+		`X-KEY:two`,
+		`END:X-VENDOR-DATA`,
+		`END:VEVENT`,
+		`BEGIN:VAVAILABILITY`,
+		`DTSTART:20111005T133225Z`,
+		`UID:availability@example.com`,
+		`BEGIN:AVAILABLE`,
+		`DTSTART:20111002T090000Z`,
+		`RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR`,
+		`SUMMARY:Monday to Friday from 9:00 to 17:00`,
+		`END:AVAILABLE`,
+		`END:VAVAILABILITY`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+
+	var found bool
+	for e := range c.Entries() {
+		comp, ok := e.(*ical.Component)
+		if !ok {
+			continue
+		}
+		found = true
+		if !assert.Equal(t, "VAVAILABILITY", comp.Type(), `component name should be retained`) {
+			return
+		}
+	}
+	if !assert.True(t, found, `unknown component should be retained`) {
+		return
+	}
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, ical.NewEncoder(&buf).Encode(c), `Encode should succeed`) {
+		return
+	}
+	if !assert.Equal(t, src, buf.String(), `round trip should be lossless`) {
+		return
+	}
+}
+
+func TestParseRepeatableProperties(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VEVENT`,
+		`ATTACH:http://example.com/agenda.pdf`,
+		`ATTENDEE;CN=A:mailto:a@example.com`,
+		`ATTENDEE;CN=B:mailto:b@example.com`,
+		`CATEGORIES:MEETING,PROJECT\, X`,
+		`COLOR:turquoise`,
+		`COMMENT:first`,
+		`COMMENT:second`,
+		`CONTACT:Jim Dolittle`,
+		`DTSTART:20200106T100000Z`,
+		`EXDATE:20200113T100000Z`,
+		`RDATE:20200301T100000Z`,
+		`RELATED-TO:parent@example.com`,
+		`REQUEST-STATUS:2.0;Success`,
+		`RESOURCES:EASEL,PROJECTOR`,
+		`RRULE:FREQ=WEEKLY;COUNT=4`,
+		`UID:repeat@example.com`,
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+
+	var ev *ical.Event
+	for e := range c.Entries() {
+		ev = e.(*ical.Event)
+	}
+	if !assert.NotNil(t, ev, `event should be parsed`) {
+		return
+	}
+
+	if !assert.Len(t, entryProps(ev, "attendee"), 2, `both attendees should be retained`) {
+		return
+	}
+	if p, ok := ev.GetProperty("categories"); !assert.True(t, ok) || !assert.Equal(t, []string{"MEETING", "PROJECT, X"}, p.Values()) {
+		return
+	}
+	if _, ok := ev.GetProperty("color"); !assert.True(t, ok, `undefined properties should be retained`) {
+		return
+	}
+
+	list, err := ev.Occurrences(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC))
+	if !assert.NoError(t, err, `Occurrences should succeed`) {
+		return
+	}
+	if !assert.Len(t, list, 4, `rrule, rdate and exdate should be applied`) {
+		return
+	}
+
+	if !assert.Equal(t, src, c.String(), `round trip should match`) {
+		return
+	}
+}
+
+func TestParseModes(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VEVENT`,
+		`this is not a property`,
+		`UID:first@example.com`,
+		`UID:second@example.com`,
+		`SUMMARY;LANGUAGE="en:Broken`,
+		`END:VTODO`,
+		`BEGIN:VEVENT`,
+		`UID:nested@example.com`,
+		`END:VCALENDAR`,
+	}, "\n") + "\n"
+
+	t.Run("lenient", func(t *testing.T) {
+		c, l, err := ical.NewParser(ical.WithLenient(true)).ParseWithWarnings(strings.NewReader(src))
+		if !assert.NoError(t, err, `Parse should succeed`) {
+			return
+		}
+
+		var warnings []string
+		for _, w := range l {
+			warnings = append(warnings, w.String())
+		}
+		expect := []string{
+			`line 1: line is terminated by LF instead of CRLF`,
+			`line 5: skipped line without ':'`,
+			`line 7: duplicate UID property in VEVENT, keeping the last one`,
+			`line 8: malformed content line: unterminated quoted parameter value in content line`,
+			`line 9: expected END:VEVENT, got END:VTODO`,
+			`line 10: VEVENT is not allowed in VEVENT`,
+			`line 12: missing END:VEVENT`,
+			`line 12: missing END:VEVENT`,
+		}
+		if !assert.Equal(t, expect, warnings, `warnings should match`) {
+			return
+		}
+
+		var uids []string
+		for e := range c.Entries() {
+			for _, p := range entryProps(e, "uid") {
+				uids = append(uids, p.RawValue())
+			}
+			for sub := range e.Entries() {
+				for _, p := range entryProps(sub, "uid") {
+					uids = append(uids, p.RawValue())
+				}
+			}
+		}
+		if !assert.Equal(t, []string{"second@example.com", "nested@example.com"}, uids, `recovered components should match`) {
+			return
+		}
+	})
+
+	t.Run("default", func(t *testing.T) {
+		_, err := ical.NewParser().Parse(strings.NewReader(src))
+		if !assert.Error(t, err, `Parse should fail`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), `line 8 in VCALENDAR/VEVENT[0]: malformed content line`, `error should point at the malformed line`) {
+			return
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := ical.NewParser(ical.WithStrict(true)).Parse(strings.NewReader(src))
+		if !assert.Error(t, err, `Parse should fail`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), `line 1: line is terminated by LF instead of CRLF`, `error should point at the first line`) {
+			return
+		}
+	})
+}
+
+func TestParseStrictValidation(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VEVENT`,
+		`UID:missing-dtstamp@example.com`,
+		`DTSTART:20200106T100000Z`,
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	if _, err := ical.NewParser().Parse(strings.NewReader(src)); !assert.NoError(t, err, `Parse should succeed by default`) {
+		return
+	}
+
+	_, err := ical.NewParser(ical.WithStrict(true)).Parse(strings.NewReader(src))
+	if !assert.Error(t, err, `Parse should fail in strict mode`) {
+		return
+	}
+	if !assert.Contains(t, err.Error(), `VCALENDAR/VEVENT[0]: dtstamp: missing mandatory property`, `error should report the validation failure`) {
+		return
+	}
+
+	dec := ical.NewDecoder(strings.NewReader(src), ical.WithStrict(true))
+	if _, err := dec.Next(); !assert.Error(t, err, `Next should fail in strict mode`) {
+		return
+	}
+	bare := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:bare@example.com\r\nDTSTAMP:20200101T000000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	_, err = ical.NewParser(ical.WithStrict(true)).Parse(strings.NewReader(bare))
+	if !assert.Error(t, err, `calendar without PRODID and VERSION should fail in strict mode`) {
+		return
+	}
+	if !assert.Contains(t, err.Error(), `VCALENDAR: prodid: missing mandatory property`, `error should report the missing property`) {
+		return
+	}
+}
+
+func TestParseNesting(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VALARM`,
+		`ACTION:DISPLAY`,
+		`TRIGGER:-PT15M`,
+		`END:VALARM`,
+		`BEGIN:X-CUSTOM`,
+		`BEGIN:X-INNER`,
+		`END:X-INNER`,
+		`END:X-CUSTOM`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	c, l, err := ical.NewParser().ParseWithWarnings(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+	var warnings []string
+	for _, w := range l {
+		warnings = append(warnings, w.String())
+	}
+	if !assert.Equal(t, []string{`line 4: VALARM is not allowed in VCALENDAR`}, warnings, `misplaced VALARM should be reported`) {
+		return
+	}
+	var types []string
+	for e := range c.Entries() {
+		types = append(types, e.Type())
+	}
+	if !assert.Equal(t, []string{"VALARM", "X-CUSTOM"}, types, `components should be retained`) {
+		return
+	}
+
+	_, err = ical.NewParser(ical.WithStrict(true)).Parse(strings.NewReader(src))
+	if !assert.Error(t, err, `Parse should fail in strict mode`) {
+		return
+	}
+	if !assert.Contains(t, err.Error(), `line 4 in VCALENDAR: VALARM is not allowed in VCALENDAR`, `error should point at the VALARM`) {
+		return
+	}
+}
+
+func TestParseError(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`BEGIN:VEVENT`,
+		`UID:first@example.com`,
+		`END:VEVENT`,
+		`BEGIN:VEVENT`,
+		`SUMMARY;LANGUAGE="en:Bro`,
+		` ken`,
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	_, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.Error(t, err, `Parse should fail`) {
+		return
+	}
+
+	var perr *ical.ParseError
+	if !assert.True(t, errors.As(err, &perr), `error should be a ParseError`) {
+		return
+	}
+	if !assert.Equal(t, 7, perr.StartLine, `start line should match`) {
+		return
+	}
+	if !assert.Equal(t, 8, perr.EndLine, `end line should match`) {
+		return
+	}
+	if !assert.Equal(t, "VCALENDAR/VEVENT[1]", perr.Path, `path should match`) {
+		return
+	}
+	if !assert.Equal(t, `SUMMARY;LANGUAGE="en:Broken`, perr.Content, `content should match`) {
+		return
+	}
+	if !assert.Contains(t, err.Error(), `lines 7-8 in VCALENDAR/VEVENT[1]: malformed content line`, `message should contain the location`) {
+		return
+	}
+	long := `SUMMARY;LANGUAGE="en:` + strings.Repeat("x", 200)
+	_, err = ical.NewParser().Parse(strings.NewReader("BEGIN:VCALENDAR\r\n" + long + "\r\nEND:VCALENDAR\r\n"))
+	if !assert.True(t, errors.As(err, &perr), `error should be a ParseError`) {
+		return
+	}
+	if !assert.Equal(t, long, perr.Content, `content should be complete`) {
+		return
+	}
+	if !assert.Contains(t, err.Error(), `(`+strconv.Quote(long[:80]+"\u2026")+`)`, `message should contain the beginning of the content only`) {
+		return
+	}
+}
+
+func TestParseUnfolding(t *testing.T) {
+	// "日本" is split in the middle of its first character
+	jp := "日本"
+	lines := []string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VEVENT`,
+		`UID:fold@example.com`,
+		`DESCRIPTION:see`,
+		` http://example.com/a:b`,
+		"\t and more",
+		`URL:http://`,
+		` example.com/x`,
+		`SUMMARY:` + jp[:1],
+		` ` + jp[1:],
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}
+	expect := map[string]string{
+		"description": "seehttp://example.com/a:b and more",
+		"url":         "http://example.com/x",
+		"summary":     jp,
+	}
+
+	for _, eol := range []string{"\r\n", "\n", "\r"} {
+		c, err := ical.NewParser().Parse(strings.NewReader(strings.Join(lines, eol) + eol))
+		if !assert.NoError(t, err, `Parse should succeed (%q)`, eol) {
+			return
+		}
+
+		var ev *ical.Event
+		for e := range c.Entries() {
+			ev = e.(*ical.Event)
+		}
+		if !assert.NotNil(t, ev, `event should be parsed (%q)`, eol) {
+			return
+		}
+		for name, value := range expect {
+			p, ok := ev.GetProperty(name)
+			if !assert.True(t, ok, `property %s should exist (%q)`, name, eol) {
+				return
+			}
+			if !assert.Equal(t, value, p.RawValue(), `value of %s should match (%q)`, name, eol) {
+				return
+			}
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	data := strings.Repeat("QUJD", 50000) // 200KB of base64
+	calendar := func(attach string) string {
+		return strings.Join([]string{
+			`BEGIN:VCALENDAR`,
+			`VERSION:2.0`,
+			`PRODID:-//Example//EN`,
+			`BEGIN:VEVENT`,
+			`UID:limits@example.com`,
+			attach,
+			`END:VEVENT`,
+			`END:VCALENDAR`,
+		}, "\r\n") + "\r\n"
+	}
+	var folded []string
+	for i := 0; i < len(data); i += 74 {
+		end := i + 74
+		if end > len(data) {
+			end = len(data)
+		}
+		folded = append(folded, " "+data[i:end])
+	}
+	attach := `ATTACH;ENCODING=BASE64;VALUE=BINARY:`
+
+	for name, src := range map[string]string{
+		"unfolded": calendar(attach + data),
+		"folded":   calendar(attach + "\r\n" + strings.Join(folded, "\r\n")),
+	} {
+		c, err := ical.NewParser().Parse(strings.NewReader(src))
+		if !assert.NoError(t, err, `%s: Parse should succeed`, name) {
+			return
+		}
+		for e := range c.Entries() {
+			p, ok := e.GetProperty("attach")
+			if !assert.True(t, ok, `%s: attach should exist`, name) {
+				return
+			}
+			if !assert.Equal(t, data, p.RawValue(), `%s: attach should match`, name) {
+				return
+			}
+		}
+
+		_, err = ical.NewParser(ical.WithMaxLineSize(1024)).Parse(strings.NewReader(src))
+		var perr *ical.ParseError
+		if !assert.True(t, errors.As(err, &perr), `%s: Parse should fail with a ParseError`, name) {
+			return
+		}
+		if !assert.Equal(t, 6, perr.StartLine, `%s: error should point at the attach line`, name) {
+			return
+		}
+		if !assert.Contains(t, perr.Error(), `exceeds the maximum size of 1024 bytes`, `%s: error should mention the limit`, name) {
+			return
+		}
+	}
+
+	nested := calendar(`BEGIN:VALARM` + "\r\n" + `BEGIN:X-NESTED` + "\r\n" + `END:X-NESTED` + "\r\n" + `END:VALARM`)
+	if _, err := ical.NewParser(ical.WithMaxDepth(4)).Parse(strings.NewReader(nested)); !assert.NoError(t, err, `Parse should succeed within the depth limit`) {
+		return
+	}
+	_, err := ical.NewParser(ical.WithMaxDepth(3)).Parse(strings.NewReader(nested))
+	if !assert.Error(t, err, `Parse should fail beyond the depth limit`) {
+		return
+	}
+	if !assert.Contains(t, err.Error(), `line 7 in VCALENDAR/VEVENT[0]/VALARM[0]: components are nested deeper than 3 levels`, `error should point at the component`) {
+		return
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	calendar := func(uid string) string {
+		return strings.Join([]string{
+			`BEGIN:VCALENDAR`,
+			`VERSION:2.0`,
+			`PRODID:-//Example//EN`,
+			`BEGIN:VEVENT`,
+			`UID:` + uid,
+			`END:VEVENT`,
+			`END:VCALENDAR`,
+		}, "\r\n") + "\r\n"
+	}
+	event := func(typ, uid string) string {
+		return "BEGIN:" + typ + "\r\nUID:" + uid + "\r\nEND:" + typ + "\r\n"
+	}
+	uids := func(c *ical.Calendar) []string {
+		var l []string
+		for e := range c.Entries() {
+			p, _ := e.GetProperty("uid")
+			l = append(l, p.RawValue())
+		}
+		return l
+	}
+
+	src := "\r\n  \r\nContent-Type: text/calendar\r\n\r\n" + calendar("a@example.com") + "\r\n" + calendar("b@example.com") + "--boundary--\r\n"
+
+	p := ical.NewParser()
+	l, found, err := p.ParseAllWithWarnings(strings.NewReader(src))
+	if !assert.NoError(t, err, `ParseAll should succeed`) {
+		return
+	}
+	if !assert.Len(t, l, 2, `there should be two calendars`) {
+		return
+	}
+	if !assert.Equal(t, []string{"a@example.com"}, uids(l[0]), `first calendar should match`) {
+		return
+	}
+	if !assert.Equal(t, []string{"b@example.com"}, uids(l[1]), `second calendar should match`) {
+		return
+	}
+	var warnings []string
+	for _, w := range found {
+		warnings = append(warnings, w.String())
+	}
+	if !assert.Equal(t, []string{
+		`line 3: skipped line outside of a calendar`,
+		`line 20: skipped line outside of a calendar`,
+	}, warnings, `junk lines should be reported`) {
+		return
+	}
+	if _, found, err := p.ParseWithWarnings(strings.NewReader(calendar("x@example.com"))); !assert.NoError(t, err, `Parse should succeed`) || !assert.Empty(t, found, `warnings should not carry over between calls`) {
+		return
+	}
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+	if !assert.Equal(t, []string{"a@example.com"}, uids(c), `Parse should return the first calendar`) {
+		return
+	}
+
+	if _, err := ical.NewParser(ical.WithStrict(true)).ParseAll(strings.NewReader(src)); !assert.Error(t, err, `junk should fail in strict mode`) {
+		return
+	}
+	if _, err := ical.NewParser().Parse(strings.NewReader("\r\n")); !assert.Error(t, err, `Parse of no calendar should fail`) {
+		return
+	}
+
+	bare := "\r\n" + event("VEVENT", "c@example.com") + event("VTODO", "d@example.com") + calendar("e@example.com") + event("VEVENT", "f@example.com")
+	l, err = ical.NewParser(ical.WithBareComponents(true)).ParseAll(strings.NewReader(bare))
+	if !assert.NoError(t, err, `ParseAll with bare components should succeed`) {
+		return
+	}
+	if !assert.Len(t, l, 3, `there should be three calendars`) {
+		return
+	}
+	for i, expect := range [][]string{{"c@example.com", "d@example.com"}, {"e@example.com"}, {"f@example.com"}} {
+		if !assert.Equal(t, expect, uids(l[i]), `calendar %d should match`, i) {
+			return
+		}
+	}
+
+	l, err = ical.NewParser().ParseAll(strings.NewReader(bare))
+	if !assert.NoError(t, err, `ParseAll should succeed`) {
+		return
+	}
+	if !assert.Len(t, l, 1, `bare components should be skipped by default`) {
+		return
+	}
+}
@@ -1,6 +1,7 @@
 package ical
 
 import (
+	"encoding/base64"
 	"sort"
 	"strings"
 )
@@ -68,9 +69,23 @@ func (s *PropertySet) Get(name string) ([]*Property, bool) {
 }
 
 func NewProperty(name, value string, params Parameters) *Property {
+	return newProperty(name, value, params, nil)
+}
+
+// newProperty creates a property. values is only used by properties
+// that hold a list of TEXT values, such as CATEGORIES
+func newProperty(name, value string, params Parameters, values []string) *Property {
+	name = strings.ToLower(name)
+	if values != nil && isTextList(name) {
+		value = strings.Join(values, ",")
+	} else {
+		values = nil
+	}
+
 	return &Property{
-		name:   strings.ToLower(name),
+		name:   name,
 		value:  value,
+		values: values,
 		params: params,
 	}
 }
@@ -79,10 +94,47 @@ func (p Property) Name() string {
 	return p.name
 }
 
+// RawValue returns the value of the property as it is written. The data
+// of binary attachments is returned in base64, or as an empty string if
+// it can not be read. Attachment.Bytes reports such errors
 func (p Property) RawValue() string {
+	if p.binary != nil {
+		b, err := p.binary.bytes()
+		if err != nil {
+			return ""
+		}
+		return base64.StdEncoding.EncodeToString(b)
+	}
 	return p.value
 }
 
+// inline returns p with its binary data, if any, encoded into its value
+func (p *Property) inline() (*Property, error) {
+	if p.binary == nil {
+		return p, nil
+	}
+	b, err := p.binary.bytes()
+	if err != nil {
+		return nil, err
+	}
+	v := *p
+	v.value = base64.StdEncoding.EncodeToString(b)
+	v.binary = nil
+	return &v, nil
+}
+
 func (p Property) Parameters() Parameters {
 	return p.params
 }
+
+// Values returns the list of values of a multi-valued TEXT property
+// such as CATEGORIES or RESOURCES. For all other properties the
+// returned list only contains the value of the property
+func (p Property) Values() []string {
+	if p.values == nil {
+		return []string{p.value}
+	}
+	l := make([]string, len(p.values))
+	copy(l, p.values)
+	return l
+}
@@ -0,0 +1,499 @@
+package ical
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type Frequency int
+
+const (
+	FreqSecondly Frequency = iota + 1
+	FreqMinutely
+	FreqHourly
+	FreqDaily
+	FreqWeekly
+	FreqMonthly
+	FreqYearly
+)
+
+var frequencyNames = map[Frequency]string{
+	FreqSecondly: "SECONDLY",
+	FreqMinutely: "MINUTELY",
+	FreqHourly:   "HOURLY",
+	FreqDaily:    "DAILY",
+	FreqWeekly:   "WEEKLY",
+	FreqMonthly:  "MONTHLY",
+	FreqYearly:   "YEARLY",
+}
+
+func (f Frequency) String() string {
+	return frequencyNames[f]
+}
+
+// Weekday represents a day of the week as used in recurrence rules.
+// Its zero value is Monday, which is also the default week start
+type Weekday int
+
+const (
+	Monday Weekday = iota
+	Tuesday
+	Wednesday
+	Thursday
+	Friday
+	Saturday
+	Sunday
+)
+
+var weekdayNames = []string{"MO", "TU", "WE", "TH", "FR", "SA", "SU"}
+
+func (w Weekday) String() string {
+	if w < Monday || w > Sunday {
+		return ""
+	}
+	return weekdayNames[w]
+}
+
+// TimeWeekday converts w to the equivalent time.Weekday
+func (w Weekday) TimeWeekday() time.Weekday {
+	return time.Weekday((int(w) + 1) % 7)
+}
+
+func weekdayOf(wd time.Weekday) Weekday {
+	return Weekday((int(wd) + 6) % 7)
+}
+
+func parseWeekday(s string) (Weekday, error) {
+	for i, n := range weekdayNames {
+		if n == s {
+			return Weekday(i), nil
+		}
+	}
+	return 0, errors.Errorf(`invalid weekday '%s'`, s)
+}
+
+// WeekdayNum is an element of BYDAY: a weekday, optionally qualified
+// with an ordinal such as the 2nd (2) or the last (-1)
+type WeekdayNum struct {
+	Ordinal int
+	Weekday Weekday
+}
+
+// Recur is the structured form of a RECUR value, as used by the
+// RRULE and EXRULE properties
+type Recur struct {
+	Freq       Frequency
+	Until      time.Time
+	Count      int
+	Interval   int
+	BySecond   []int
+	ByMinute   []int
+	ByHour     []int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByYearDay  []int
+	ByWeekNo   []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  Weekday
+
+	// untilDate is true if UNTIL was (or should be) a DATE value.
+	// untilFloating is true if UNTIL was a DATE-TIME without the UTC
+	// designator, in which case Until holds the wall clock in UTC
+	untilDate     bool
+	untilFloating bool
+}
+
+// ParseRecur parses a RECUR value such as "FREQ=WEEKLY;BYDAY=MO,WE"
+func ParseRecur(s string) (*Recur, error) {
+	var r Recur
+	var hasUntil bool
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf(`invalid recur rule part '%s'`, part)
+		}
+		name, value := strings.ToUpper(kv[0]), strings.ToUpper(kv[1])
+
+		var err error
+		switch name {
+		case "FREQ":
+			var found bool
+			for f, n := range frequencyNames {
+				if n == value {
+					r.Freq = f
+					found = true
+					break
+				}
+			}
+			if !found {
+				err = errors.Errorf(`invalid frequency '%s'`, value)
+			}
+		case "UNTIL":
+			hasUntil = true
+			err = r.parseUntil(value)
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = errors.Errorf(`invalid count '%s'`, value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = errors.Errorf(`invalid interval '%s'`, value)
+			}
+		case "BYSECOND":
+			r.BySecond, err = parseRecurInts(value, 0, 60, false)
+		case "BYMINUTE":
+			r.ByMinute, err = parseRecurInts(value, 0, 59, false)
+		case "BYHOUR":
+			r.ByHour, err = parseRecurInts(value, 0, 23, false)
+		case "BYDAY":
+			r.ByDay, err = parseRecurWeekdays(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseRecurInts(value, 1, 31, true)
+		case "BYYEARDAY":
+			r.ByYearDay, err = parseRecurInts(value, 1, 366, true)
+		case "BYWEEKNO":
+			r.ByWeekNo, err = parseRecurInts(value, 1, 53, true)
+		case "BYMONTH":
+			r.ByMonth, err = parseRecurInts(value, 1, 12, false)
+		case "BYSETPOS":
+			r.BySetPos, err = parseRecurInts(value, 1, 366, true)
+		case "WKST":
+			r.WeekStart, err = parseWeekday(value)
+		default:
+			// x-name and iana rule parts are ignored
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to parse %s`, name)
+		}
+	}
+
+	if r.Freq == 0 {
+		return nil, errors.New(`recur rule is missing FREQ`)
+	}
+	if hasUntil && r.Count > 0 {
+		return nil, errors.New(`recur rule may not contain both UNTIL and COUNT`)
+	}
+	return &r, nil
+}
+
+func (r *Recur) parseUntil(s string) error {
+	t, isDate, err := parseDateTime(s, time.UTC)
+	if err != nil {
+		return err
+	}
+	r.Until = t
+	r.untilDate = isDate
+	r.untilFloating = !isDate && !strings.HasSuffix(s, "Z")
+	return nil
+}
+
+func parseRecurInts(s string, min, max int, signed bool) ([]int, error) {
+	var l []int
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, `invalid value '%s'`, v)
+		}
+
+		abs := n
+		if signed && n < 0 {
+			abs = -n
+		}
+		if abs < min || abs > max || (signed && n == 0) {
+			return nil, errors.Errorf(`value '%s' out of range`, v)
+		}
+		l = append(l, n)
+	}
+	return l, nil
+}
+
+func parseRecurWeekdays(s string) ([]WeekdayNum, error) {
+	var l []WeekdayNum
+	for _, v := range strings.Split(s, ",") {
+		if len(v) < 2 {
+			return nil, errors.Errorf(`invalid weekday '%s'`, v)
+		}
+
+		wd, err := parseWeekday(v[len(v)-2:])
+		if err != nil {
+			return nil, err
+		}
+
+		var ord int
+		if n := v[:len(v)-2]; n != "" {
+			ord, err = strconv.Atoi(n)
+			if err != nil || ord == 0 || ord > 53 || ord < -53 {
+				return nil, errors.Errorf(`invalid weekday ordinal '%s'`, v)
+			}
+		}
+		l = append(l, WeekdayNum{Ordinal: ord, Weekday: wd})
+	}
+	return l, nil
+}
+
+// Validate checks the rule for combinations of rule parts that are not
+// allowed by RFC 5545
+func (r *Recur) Validate() error {
+	if _, ok := frequencyNames[r.Freq]; !ok {
+		return errors.New(`recur rule has no valid FREQ`)
+	}
+	if !r.Until.IsZero() && r.Count > 0 {
+		return errors.New(`UNTIL and COUNT may not be specified together`)
+	}
+	if r.Count < 0 {
+		return errors.Errorf(`invalid COUNT %d`, r.Count)
+	}
+	if r.Interval < 0 {
+		return errors.Errorf(`invalid INTERVAL %d`, r.Interval)
+	}
+
+	ranges := []struct {
+		name   string
+		values []int
+		min    int
+		max    int
+		signed bool
+	}{
+		{"BYSECOND", r.BySecond, 0, 60, false},
+		{"BYMINUTE", r.ByMinute, 0, 59, false},
+		{"BYHOUR", r.ByHour, 0, 23, false},
+		{"BYMONTHDAY", r.ByMonthDay, 1, 31, true},
+		{"BYYEARDAY", r.ByYearDay, 1, 366, true},
+		{"BYWEEKNO", r.ByWeekNo, 1, 53, true},
+		{"BYMONTH", r.ByMonth, 1, 12, false},
+		{"BYSETPOS", r.BySetPos, 1, 366, true},
+	}
+	for _, rng := range ranges {
+		for _, n := range rng.values {
+			abs := n
+			if rng.signed && n < 0 {
+				abs = -n
+			}
+			if abs < rng.min || abs > rng.max || (rng.signed && n == 0) {
+				return errors.Errorf(`%s value %d out of range`, rng.name, n)
+			}
+		}
+	}
+
+	for _, wd := range r.ByDay {
+		if wd.Weekday < Monday || wd.Weekday > Sunday {
+			return errors.Errorf(`invalid BYDAY weekday %d`, wd.Weekday)
+		}
+		if wd.Ordinal == 0 {
+			continue
+		}
+		if wd.Ordinal > 53 || wd.Ordinal < -53 {
+			return errors.Errorf(`BYDAY ordinal %d out of range`, wd.Ordinal)
+		}
+		if r.Freq != FreqMonthly && r.Freq != FreqYearly {
+			return errors.New(`BYDAY ordinals may only be used with MONTHLY or YEARLY rules`)
+		}
+		if r.Freq == FreqYearly && len(r.ByWeekNo) > 0 {
+			return errors.New(`BYDAY ordinals may not be used together with BYWEEKNO`)
+		}
+	}
+	if r.WeekStart < Monday || r.WeekStart > Sunday {
+		return errors.Errorf(`invalid WKST %d`, r.WeekStart)
+	}
+
+	if len(r.ByWeekNo) > 0 && r.Freq != FreqYearly {
+		return errors.New(`BYWEEKNO may only be used with YEARLY rules`)
+	}
+	if len(r.ByYearDay) > 0 {
+		switch r.Freq {
+		case FreqDaily, FreqWeekly, FreqMonthly:
+			return errors.Errorf(`BYYEARDAY may not be used with %s rules`, r.Freq)
+		}
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq == FreqWeekly {
+		return errors.New(`BYMONTHDAY may not be used with WEEKLY rules`)
+	}
+	if len(r.BySetPos) > 0 && len(r.BySecond) == 0 && len(r.ByMinute) == 0 && len(r.ByHour) == 0 && len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByYearDay) == 0 && len(r.ByWeekNo) == 0 && len(r.ByMonth) == 0 {
+		return errors.New(`BYSETPOS must be used together with another BYxxx rule part`)
+	}
+	return nil
+}
+
+// String returns the canonical text form of the rule, suitable for use
+// as the value of an RRULE or EXRULE property
+func (r *Recur) String() string {
+	var parts []string
+	parts = append(parts, "FREQ="+r.Freq.String())
+
+	if !r.Until.IsZero() {
+		var until string
+		switch {
+		case r.untilDate:
+			until = r.Until.Format(dateFormat)
+		case r.untilFloating:
+			until = r.Until.Format(dateTimeFormat)
+		default:
+			until = r.Until.UTC().Format(utcDateTimeFormat)
+		}
+		parts = append(parts, "UNTIL="+until)
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	writeInts := func(name string, l []int) {
+		if len(l) == 0 {
+			return
+		}
+		s := make([]string, len(l))
+		for i, n := range l {
+			s[i] = strconv.Itoa(n)
+		}
+		parts = append(parts, name+"="+strings.Join(s, ","))
+	}
+
+	writeInts("BYSECOND", r.BySecond)
+	writeInts("BYMINUTE", r.ByMinute)
+	writeInts("BYHOUR", r.ByHour)
+	if len(r.ByDay) > 0 {
+		s := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			s[i] = wd.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(s, ","))
+	}
+	writeInts("BYMONTHDAY", r.ByMonthDay)
+	writeInts("BYYEARDAY", r.ByYearDay)
+	writeInts("BYWEEKNO", r.ByWeekNo)
+	writeInts("BYMONTH", r.ByMonth)
+	writeInts("BYSETPOS", r.BySetPos)
+	if r.WeekStart != Monday {
+		parts = append(parts, "WKST="+r.WeekStart.String())
+	}
+	return strings.Join(parts, ";")
+}
+
+func (wd WeekdayNum) String() string {
+	if wd.Ordinal == 0 {
+		return wd.Weekday.String()
+	}
+	return strconv.Itoa(wd.Ordinal) + wd.Weekday.String()
+}
+
+// RecurBuilder constructs a Recur. Errors are reported by Build
+type RecurBuilder struct {
+	recur Recur
+}
+
+func NewRecurBuilder(freq Frequency) *RecurBuilder {
+	return &RecurBuilder{
+		recur: Recur{Freq: freq},
+	}
+}
+
+// Until sets UNTIL to the DATE-TIME t, which is written in UTC
+func (b *RecurBuilder) Until(t time.Time) *RecurBuilder {
+	b.recur.Until = t.UTC()
+	b.recur.untilDate = false
+	b.recur.untilFloating = false
+	return b
+}
+
+// UntilDate sets UNTIL to the DATE of t, for use with rules whose
+// DTSTART is a DATE
+func (b *RecurBuilder) UntilDate(t time.Time) *RecurBuilder {
+	y, m, d := t.Date()
+	b.recur.Until = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	b.recur.untilDate = true
+	b.recur.untilFloating = false
+	return b
+}
+
+func (b *RecurBuilder) Count(n int) *RecurBuilder {
+	b.recur.Count = n
+	return b
+}
+
+func (b *RecurBuilder) Interval(n int) *RecurBuilder {
+	b.recur.Interval = n
+	return b
+}
+
+func (b *RecurBuilder) BySecond(l ...int) *RecurBuilder {
+	b.recur.BySecond = append(b.recur.BySecond, l...)
+	return b
+}
+
+func (b *RecurBuilder) ByMinute(l ...int) *RecurBuilder {
+	b.recur.ByMinute = append(b.recur.ByMinute, l...)
+	return b
+}
+
+func (b *RecurBuilder) ByHour(l ...int) *RecurBuilder {
+	b.recur.ByHour = append(b.recur.ByHour, l...)
+	return b
+}
+
+// ByDay adds weekdays without an ordinal, meaning every such weekday
+// within the period
+func (b *RecurBuilder) ByDay(l ...Weekday) *RecurBuilder {
+	for _, wd := range l {
+		b.recur.ByDay = append(b.recur.ByDay, WeekdayNum{Weekday: wd})
+	}
+	return b
+}
+
+// ByDayNum adds a weekday with an ordinal, such as the second (2)
+// or last (-1) friday of the period
+func (b *RecurBuilder) ByDayNum(ordinal int, wd Weekday) *RecurBuilder {
+	b.recur.ByDay = append(b.recur.ByDay, WeekdayNum{Ordinal: ordinal, Weekday: wd})
+	return b
+}
+
+func (b *RecurBuilder) ByMonthDay(l ...int) *RecurBuilder {
+	b.recur.ByMonthDay = append(b.recur.ByMonthDay, l...)
+	return b
+}
+
+func (b *RecurBuilder) ByYearDay(l ...int) *RecurBuilder {
+	b.recur.ByYearDay = append(b.recur.ByYearDay, l...)
+	return b
+}
+
+func (b *RecurBuilder) ByWeekNo(l ...int) *RecurBuilder {
+	b.recur.ByWeekNo = append(b.recur.ByWeekNo, l...)
+	return b
+}
+
+func (b *RecurBuilder) ByMonth(l ...time.Month) *RecurBuilder {
+	for _, m := range l {
+		b.recur.ByMonth = append(b.recur.ByMonth, int(m))
+	}
+	return b
+}
+
+func (b *RecurBuilder) BySetPos(l ...int) *RecurBuilder {
+	b.recur.BySetPos = append(b.recur.BySetPos, l...)
+	return b
+}
+
+func (b *RecurBuilder) WeekStart(wd Weekday) *RecurBuilder {
+	b.recur.WeekStart = wd
+	return b
+}
+
+// Build validates and returns the rule
+func (b *RecurBuilder) Build() (*Recur, error) {
+	r := b.recur
+	if err := r.Validate(); err != nil {
+		return nil, errors.Wrap(err, `invalid recur rule`)
+	}
+	return &r, nil
+}
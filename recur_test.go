@@ -0,0 +1,76 @@
+package ical_test
+
+import (
+	"testing"
+	"time"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecurRoundTrip(t *testing.T) {
+	rules := []string{
+		"FREQ=DAILY;COUNT=10",
+		"FREQ=WEEKLY;UNTIL=19971224T000000Z;INTERVAL=2;BYDAY=MO,WE,FR;WKST=SU",
+		"FREQ=MONTHLY;BYDAY=-2MO;BYSETPOS=1,-1",
+		"FREQ=YEARLY;UNTIL=20001231;BYMONTHDAY=1,-1;BYMONTH=1,2,3",
+		"FREQ=MINUTELY;UNTIL=20000101T090000;INTERVAL=20;BYHOUR=9,10,11,12,13,14,15,16",
+	}
+
+	for _, rule := range rules {
+		r, err := ical.ParseRecur(rule)
+		if !assert.NoError(t, err, `ParseRecur should succeed for %s`, rule) {
+			return
+		}
+		if !assert.Equal(t, rule, r.String(), `serialized rule should match`) {
+			return
+		}
+	}
+
+	for _, rule := range []string{"COUNT=10", "FREQ=DAILY;COUNT=1;UNTIL=20000101", "FREQ=WEEKLY;BYDAY=XX"} {
+		_, err := ical.ParseRecur(rule)
+		if !assert.Error(t, err, `ParseRecur should fail for %s`, rule) {
+			return
+		}
+	}
+}
+
+func TestRecurBuilder(t *testing.T) {
+	r, err := ical.NewRecurBuilder(ical.FreqMonthly).
+		Interval(2).
+		ByDayNum(-1, ical.Friday).
+		Until(time.Date(2020, 12, 31, 9, 0, 0, 0, time.FixedZone("JST", 9*3600))).
+		Build()
+	if !assert.NoError(t, err, `Build should succeed`) {
+		return
+	}
+	if !assert.Equal(t, "FREQ=MONTHLY;UNTIL=20201231T000000Z;INTERVAL=2;BYDAY=-1FR", r.String(), `serialized rule should match`) {
+		return
+	}
+
+	invalid := map[string]*ical.RecurBuilder{
+		"count with until":     ical.NewRecurBuilder(ical.FreqDaily).Count(3).Until(time.Now()),
+		"byweekno in monthly":  ical.NewRecurBuilder(ical.FreqMonthly).ByWeekNo(20),
+		"byyearday in weekly":  ical.NewRecurBuilder(ical.FreqWeekly).ByYearDay(100),
+		"bymonthday in weekly": ical.NewRecurBuilder(ical.FreqWeekly).ByMonthDay(1),
+		"ordinal in daily":     ical.NewRecurBuilder(ical.FreqDaily).ByDayNum(1, ical.Monday),
+		"bysetpos alone":       ical.NewRecurBuilder(ical.FreqMonthly).BySetPos(1),
+		"bymonth out of range": ical.NewRecurBuilder(ical.FreqYearly).ByMonth(13),
+	}
+	for name, b := range invalid {
+		_, err := b.Build()
+		if !assert.Error(t, err, `Build should fail for %s`, name) {
+			return
+		}
+	}
+
+	e := ical.NewTodo()
+	e.AddProperty("rrule", "FREQ=WEEKLY;BYDAY=MO,WE")
+	e.AddProperty("exrule", "FREQ=WEEKLY;BYDAY=WE;INTERVAL=2")
+	if !assert.Contains(t, e.String(), "\r\nRRULE:FREQ=WEEKLY;BYDAY=MO,WE\r\n", `rrule should not be escaped`) {
+		return
+	}
+	if !assert.Contains(t, e.String(), "\r\nEXRULE:FREQ=WEEKLY;BYDAY=WE;INTERVAL=2\r\n", `exrule should not be escaped`) {
+		return
+	}
+}
@@ -0,0 +1,806 @@
+package ical
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Occurrence is a single instance of a (possibly recurring) component
+type Occurrence struct {
+	// Start and End delimit the instance. End equals Start for
+	// components that have no duration
+	Start time.Time
+	End   time.Time
+
+	// RecurrenceID is the start of the instance as computed from the
+	// recurrence set, before any RECURRENCE-ID override was applied
+	RecurrenceID time.Time
+
+	// Entry is the component that defines this instance. This is
+	// either the master component, or the component overriding it
+	Entry Entry
+}
+
+// Occurrences expands the recurrence set of the event (DTSTART, RRULE,
+// RDATE, EXRULE and EXDATE) and returns the instances that overlap with
+// the [start, end) window, sorted by their start time.
+//
+// overrides are the events sharing the UID of this event that carry a
+// RECURRENCE-ID. Floating times are interpreted in the location of start.
+func (v *Event) Occurrences(start, end time.Time, overrides ...*Event) ([]*Occurrence, error) {
+	l := make([]Entry, len(overrides))
+	for i, o := range overrides {
+		l[i] = o
+	}
+	return expandOccurrences(v, l, start, end, loadLocation)
+}
+
+// Occurrences expands the recurrence set of the todo. See
+// Event.Occurrences for details
+func (v *Todo) Occurrences(start, end time.Time, overrides ...*Todo) ([]*Occurrence, error) {
+	l := make([]Entry, len(overrides))
+	for i, o := range overrides {
+		l[i] = o
+	}
+	return expandOccurrences(v, l, start, end, loadLocation)
+}
+
+// Occurrences expands all events and todos in the calendar, applying
+// RECURRENCE-ID overrides that share the UID of their master component.
+// TZIDs are resolved with Calendar.Location
+func (v *Calendar) Occurrences(start, end time.Time) ([]*Occurrence, error) {
+	type group struct {
+		master    Entry
+		overrides []Entry
+	}
+
+	var groups []*group
+	byUID := make(map[string]*group)
+	for e := range v.Entries() {
+		switch e.(type) {
+		case *Event, *Todo:
+		default:
+			continue
+		}
+
+		var uid string
+		if p, ok := e.GetProperty("uid"); ok {
+			uid = e.Type() + ":" + p.RawValue()
+		}
+
+		g, ok := byUID[uid]
+		if !ok || uid == "" {
+			g = &group{}
+			groups = append(groups, g)
+			if uid != "" {
+				byUID[uid] = g
+			}
+		}
+
+		if _, ok := e.GetProperty("recurrence-id"); ok && uid != "" {
+			g.overrides = append(g.overrides, e)
+		} else {
+			g.master = e
+		}
+	}
+
+	resolve := v.resolver()
+	var list []*Occurrence
+	for _, g := range groups {
+		if g.master == nil {
+			// overrides without a master are plain instances
+			for _, o := range g.overrides {
+				l, err := expandOccurrences(o, nil, start, end, resolve)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, l...)
+			}
+			continue
+		}
+
+		l, err := expandOccurrences(g.master, g.overrides, start, end, resolve)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, l...)
+	}
+
+	sortOccurrences(list)
+	return list, nil
+}
+
+func sortOccurrences(l []*Occurrence) {
+	sort.SliceStable(l, func(i, j int) bool {
+		return l[i].Start.Before(l[j].Start)
+	})
+}
+
+func entryProperties(e Entry, name string) []*Property {
+	var l []*Property
+	for p := range e.Properties() {
+		if p.Name() == name {
+			l = append(l, p)
+		}
+	}
+	return l
+}
+
+// entryTime parses the DATE or DATE-TIME value of the named property
+func entryTime(e Entry, name string, floating *time.Location, resolve locationResolver) (time.Time, bool, bool, error) {
+	p, ok := e.GetProperty(name)
+	if !ok {
+		return time.Time{}, false, false, nil
+	}
+
+	loc, err := propertyLocation(p, floating, resolve)
+	if err != nil {
+		return time.Time{}, false, false, errors.Wrapf(err, `failed to resolve location for %s`, name)
+	}
+
+	t, isDate, err := parseDateTime(p.RawValue(), loc)
+	if err != nil {
+		return time.Time{}, false, false, errors.Wrapf(err, `failed to parse %s`, name)
+	}
+	return t, isDate, true, nil
+}
+
+// entrySpan returns the start and duration of a component. The span of
+// an all-day component is counted in days
+func entrySpan(e Entry, floating *time.Location, resolve locationResolver) (time.Time, nominalDuration, bool, error) {
+	dtstart, isDate, ok, err := entryTime(e, "dtstart", floating, resolve)
+	if err != nil {
+		return time.Time{}, nominalDuration{}, false, err
+	}
+	if !ok {
+		return time.Time{}, nominalDuration{}, false, errors.Errorf(`%s has no DTSTART`, e.Type())
+	}
+
+	for _, name := range []string{"dtend", "due"} {
+		t, _, ok, err := entryTime(e, name, floating, resolve)
+		if err != nil {
+			return time.Time{}, nominalDuration{}, false, err
+		}
+		if !ok {
+			continue
+		}
+		if isDate {
+			days := wallClock(t).Sub(wallClock(dtstart)) / (24 * time.Hour)
+			return dtstart, nominalDuration{days: int(days)}, isDate, nil
+		}
+		return dtstart, nominalDuration{exact: t.Sub(dtstart)}, isDate, nil
+	}
+
+	if p, ok := e.GetProperty("duration"); ok {
+		d, err := parseDuration(p.RawValue())
+		if err != nil {
+			return time.Time{}, nominalDuration{}, false, errors.Wrap(err, `failed to parse duration`)
+		}
+		return dtstart, d, isDate, nil
+	}
+
+	if isDate && e.Type() == "VEVENT" {
+		return dtstart, nominalDuration{days: 1}, isDate, nil
+	}
+	return dtstart, nominalDuration{}, isDate, nil
+}
+
+// propertyTimes parses the (possibly comma separated) DATE, DATE-TIME or
+// PERIOD values of p, as found in RDATE and EXDATE
+func propertyTimes(p *Property, floating *time.Location, resolve locationResolver) ([]time.Time, []time.Time, bool, error) {
+	loc, err := propertyLocation(p, floating, resolve)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	vt, _ := p.params.Get("VALUE")
+	var starts, ends []time.Time
+	var isDate bool
+	for _, s := range strings.Split(p.RawValue(), ",") {
+		if strings.EqualFold(vt, "PERIOD") {
+			start, end, err := parsePeriod(s, loc)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			starts = append(starts, start)
+			ends = append(ends, end)
+			continue
+		}
+
+		t, d, err := parseDateTime(s, loc)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		starts = append(starts, t)
+		ends = append(ends, time.Time{})
+		isDate = d
+	}
+	return starts, ends, isDate, nil
+}
+
+func expandOccurrences(e Entry, overrides []Entry, start, end time.Time, resolve locationResolver) ([]*Occurrence, error) {
+	if !start.Before(end) {
+		return nil, errors.New(`start must be before end`)
+	}
+
+	floating := start.Location()
+	dtstart, duration, isDate, err := entrySpan(e, floating, resolve)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to compute span`)
+	}
+
+	// instances of the rules that start before keep cannot overlap the
+	// window, so they are only counted towards COUNT and not stored
+	margin, err := lookback(duration, overrides, floating, resolve)
+	if err != nil {
+		return nil, err
+	}
+	keep := start.Add(-margin)
+
+	instances := make(map[int64]*Occurrence)
+	add := func(t time.Time, tend time.Time) {
+		if tend.IsZero() {
+			tend = duration.addTo(t)
+		}
+		instances[t.UnixNano()] = &Occurrence{
+			Start:        t,
+			End:          tend,
+			RecurrenceID: t,
+			Entry:        e,
+		}
+	}
+
+	add(dtstart, time.Time{})
+	for _, p := range entryProperties(e, "rrule") {
+		r, err := p.Recur()
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse rrule`)
+		}
+		err = r.iterate(dtstart, keep, end, func(t time.Time) bool {
+			if !t.Before(keep) {
+				add(t, time.Time{})
+			}
+			return true
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to expand rrule`)
+		}
+	}
+
+	for _, p := range entryProperties(e, "rdate") {
+		starts, ends, _, err := propertyTimes(p, dtstart.Location(), resolve)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse rdate`)
+		}
+		for i := range starts {
+			add(starts[i], ends[i])
+		}
+	}
+
+	for _, p := range entryProperties(e, "exrule") {
+		r, err := p.Recur()
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse exrule`)
+		}
+		err = r.iterate(dtstart, keep, end, func(t time.Time) bool {
+			delete(instances, t.UnixNano())
+			return true
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to expand exrule`)
+		}
+	}
+
+	for _, p := range entryProperties(e, "exdate") {
+		starts, _, exDate, err := propertyTimes(p, dtstart.Location(), resolve)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse exdate`)
+		}
+		for _, t := range starts {
+			if exDate && !isDate {
+				// a DATE excludes every instance on that day
+				y, m, d := t.Date()
+				for k, o := range instances {
+					oy, om, od := o.Start.In(t.Location()).Date()
+					if oy == y && om == m && od == d {
+						delete(instances, k)
+					}
+				}
+				continue
+			}
+			delete(instances, t.UnixNano())
+		}
+	}
+
+	list := make([]*Occurrence, 0, len(instances))
+	for _, o := range instances {
+		list = append(list, o)
+	}
+	sortOccurrences(list)
+
+	list, err = applyOverrides(list, overrides, floating, resolve)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := list[:0]
+	for _, o := range list {
+		if overlaps(o, start, end) {
+			filtered = append(filtered, o)
+		}
+	}
+	sortOccurrences(filtered)
+	return filtered, nil
+}
+
+// lookback returns how long before the window an instance may start
+// and still overlap it, either by its duration or after being shifted
+// by a THISANDFUTURE override. A day is added to cover nominal days
+// that are longer than 24 hours
+func lookback(duration nominalDuration, overrides []Entry, floating *time.Location, resolve locationResolver) (time.Duration, error) {
+	margin := duration.approx()
+	for _, o := range overrides {
+		p, ok := o.GetProperty("recurrence-id")
+		if !ok {
+			continue
+		}
+		if v, ok := p.params.Get("RANGE"); !ok || !strings.EqualFold(v, "THISANDFUTURE") {
+			continue
+		}
+
+		rid, _, _, err := entryTime(o, "recurrence-id", floating, resolve)
+		if err != nil {
+			return 0, errors.Wrap(err, `failed to parse override`)
+		}
+		ostart, oduration, _, err := entrySpan(o, floating, resolve)
+		if err != nil {
+			return 0, errors.Wrap(err, `failed to parse override`)
+		}
+		if d := ostart.Sub(rid) + oduration.approx(); d > margin {
+			margin = d
+		}
+	}
+	if margin < 0 {
+		margin = 0
+	}
+	return margin + 24*time.Hour, nil
+}
+
+func overlaps(o *Occurrence, start, end time.Time) bool {
+	if !o.Start.Before(end) {
+		return false
+	}
+	if o.End.After(o.Start) {
+		return o.End.After(start)
+	}
+	return !o.Start.Before(start)
+}
+
+// applyOverrides replaces the instances identified by the RECURRENCE-ID
+// of each override. Overrides with RANGE=THISANDFUTURE also apply to all
+// later instances, shifting them by the same amount
+func applyOverrides(list []*Occurrence, overrides []Entry, floating *time.Location, resolve locationResolver) ([]*Occurrence, error) {
+	for _, o := range overrides {
+		rid, _, ok, err := entryTime(o, "recurrence-id", floating, resolve)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse override`)
+		}
+		if !ok {
+			continue
+		}
+
+		ostart, oduration, _, err := entrySpan(o, floating, resolve)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse override`)
+		}
+
+		var future bool
+		if p, _ := o.GetProperty("recurrence-id"); p != nil {
+			if v, ok := p.params.Get("RANGE"); ok && strings.EqualFold(v, "THISANDFUTURE") {
+				future = true
+			}
+		}
+
+		shift := ostart.Sub(rid)
+		replaced := false
+		for _, inst := range list {
+			switch {
+			case inst.RecurrenceID.Equal(rid):
+				replaced = true
+			case future && inst.RecurrenceID.After(rid):
+			default:
+				continue
+			}
+			inst.Start = inst.RecurrenceID.Add(shift)
+			inst.End = oduration.addTo(inst.Start)
+			inst.Entry = o
+		}
+
+		if !replaced {
+			list = append(list, &Occurrence{
+				Start:        ostart,
+				End:          oduration.addTo(ostart),
+				RecurrenceID: rid,
+				Entry:        o,
+			})
+		}
+	}
+	return list, nil
+}
+
+// maxRecurSteps limits the number of periods and candidate instances
+// that a single call to iterate examines
+const maxRecurSteps = 1000000
+
+// iterate calls fn for each instance generated by the rule starting at
+// dtstart, in chronological order, until the rule is exhausted, the
+// instance is at or after end, or fn returns false. Unless the rule has
+// a COUNT, the periods that end before from are skipped, so fn may or
+// may not be called for instances before from.
+//
+// All computations are done on wall clock times represented in UTC,
+// which are converted to the location of dtstart when they are emitted
+func (r *Recur) iterate(dtstart, from, end time.Time, fn func(time.Time) bool) error {
+	loc := dtstart.Location()
+	start := wallClock(dtstart)
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	f := dayFilter{
+		freq:       r.Freq,
+		wkst:       r.WeekStart.TimeWeekday(),
+		byMonth:    r.ByMonth,
+		byWeekNo:   r.ByWeekNo,
+		byYearDay:  r.ByYearDay,
+		byDay:      r.ByDay,
+		byMonthDay: r.ByMonthDay,
+	}
+	if len(f.byWeekNo) == 0 && len(f.byYearDay) == 0 && len(f.byMonthDay) == 0 && len(f.byDay) == 0 {
+		switch r.Freq {
+		case FreqYearly:
+			if len(f.byMonth) == 0 {
+				f.byMonth = []int{int(start.Month())}
+			}
+			f.byMonthDay = []int{start.Day()}
+		case FreqMonthly:
+			f.byMonthDay = []int{start.Day()}
+		case FreqWeekly:
+			f.byDay = []WeekdayNum{{Weekday: weekdayOf(start.Weekday())}}
+		}
+	}
+
+	hours := sortedOrDefault(r.ByHour, start.Hour())
+	minutes := sortedOrDefault(r.ByMinute, start.Minute())
+	seconds := sortedOrDefault(r.BySecond, start.Second())
+
+	var count int
+	emit := func(t time.Time) bool {
+		if t.Before(start) {
+			return true
+		}
+
+		actual := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+		if !r.Until.IsZero() {
+			switch {
+			case r.untilDate:
+				if !t.Before(r.Until.AddDate(0, 0, 1)) {
+					return false
+				}
+			case r.untilFloating:
+				if t.After(r.Until) {
+					return false
+				}
+			default:
+				if actual.After(r.Until) {
+					return false
+				}
+			}
+		}
+
+		if !actual.Before(end) {
+			return false
+		}
+
+		count++
+		if !fn(actual) {
+			return false
+		}
+		return r.Count == 0 || count < r.Count
+	}
+
+	var k int
+	if r.Count == 0 && from.After(dtstart) {
+		// start a day early, as the wall clock of from is only known
+		// in the location of dtstart
+		k = skipPeriods(r.Freq, start, wallClock(from.In(loc)).AddDate(0, 0, -1), interval)
+	}
+
+	var steps int
+	var candidates []time.Time
+	for ; ; k++ {
+		candidates = candidates[:0]
+
+		var pstart time.Time
+		switch r.Freq {
+		case FreqYearly, FreqMonthly, FreqWeekly, FreqDaily:
+			var pend time.Time
+			var year int
+			switch r.Freq {
+			case FreqYearly:
+				year = start.Year() + k*interval
+				if len(f.byWeekNo) > 0 {
+					pstart, pend = weekOneStart(year, f.wkst), weekOneStart(year+1, f.wkst)
+				} else {
+					pstart = time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+					pend = pstart.AddDate(1, 0, 0)
+				}
+			case FreqMonthly:
+				idx := start.Year()*12 + int(start.Month()) - 1 + k*interval
+				pstart = time.Date(idx/12, time.Month(idx%12+1), 1, 0, 0, 0, 0, time.UTC)
+				pend = pstart.AddDate(0, 1, 0)
+			case FreqWeekly:
+				day := start.Truncate(24 * time.Hour)
+				offset := (int(day.Weekday()) - int(f.wkst) + 7) % 7
+				pstart = day.AddDate(0, 0, k*interval*7-offset)
+				pend = pstart.AddDate(0, 0, 7)
+			case FreqDaily:
+				pstart = start.Truncate(24*time.Hour).AddDate(0, 0, k*interval)
+				pend = pstart.AddDate(0, 0, 1)
+			}
+
+			for d := pstart; d.Before(pend); d = d.AddDate(0, 0, 1) {
+				if !f.match(d, year) {
+					continue
+				}
+				for _, h := range hours {
+					for _, m := range minutes {
+						for _, s := range seconds {
+							candidates = append(candidates, d.Add(time.Duration(h)*time.Hour+time.Duration(m)*time.Minute+time.Duration(s)*time.Second))
+						}
+					}
+				}
+			}
+		case FreqHourly:
+			pstart = start.Truncate(time.Hour).Add(time.Duration(k*interval) * time.Hour)
+			if f.match(pstart, 0) && (len(r.ByHour) == 0 || containsInt(r.ByHour, pstart.Hour())) {
+				for _, m := range minutes {
+					for _, s := range seconds {
+						candidates = append(candidates, pstart.Add(time.Duration(m)*time.Minute+time.Duration(s)*time.Second))
+					}
+				}
+			}
+		case FreqMinutely:
+			pstart = start.Truncate(time.Minute).Add(time.Duration(k*interval) * time.Minute)
+			if f.match(pstart, 0) && (len(r.ByHour) == 0 || containsInt(r.ByHour, pstart.Hour())) && (len(r.ByMinute) == 0 || containsInt(r.ByMinute, pstart.Minute())) {
+				for _, s := range seconds {
+					candidates = append(candidates, pstart.Add(time.Duration(s)*time.Second))
+				}
+			}
+		case FreqSecondly:
+			pstart = start.Add(time.Duration(k*interval) * time.Second)
+			if f.match(pstart, 0) && (len(r.ByHour) == 0 || containsInt(r.ByHour, pstart.Hour())) && (len(r.ByMinute) == 0 || containsInt(r.ByMinute, pstart.Minute())) && (len(r.BySecond) == 0 || containsInt(r.BySecond, pstart.Second())) {
+				candidates = append(candidates, pstart)
+			}
+		default:
+			return nil
+		}
+
+		// stop once the period is past the window or the rule's UNTIL
+		if pstart.Year() > 9999 {
+			return nil
+		}
+		if p := time.Date(pstart.Year(), pstart.Month(), pstart.Day(), pstart.Hour(), pstart.Minute(), pstart.Second(), 0, loc); !p.Before(end) {
+			return nil
+		}
+		if !r.Until.IsZero() && pstart.After(wallClock(r.Until).Add(24*time.Hour)) {
+			return nil
+		}
+
+		steps += 1 + len(candidates)
+		if steps > maxRecurSteps {
+			return errors.Errorf(`recur rule expands to more than %d steps`, maxRecurSteps)
+		}
+
+		if len(r.BySetPos) > 0 {
+			candidates = selectSetPos(candidates, r.BySetPos)
+		}
+
+		for _, t := range candidates {
+			if !emit(t) {
+				return nil
+			}
+		}
+	}
+}
+
+// skipPeriods returns the number of whole periods of the rule that lie
+// between start and target, rounded down, minus one to leave room for
+// periods that do not start at start
+func skipPeriods(freq Frequency, start, target time.Time, interval int) int {
+	var n int64
+	switch freq {
+	case FreqYearly:
+		n = int64(target.Year() - start.Year())
+	case FreqMonthly:
+		n = int64((target.Year()-start.Year())*12 + int(target.Month()) - int(start.Month()))
+	case FreqWeekly:
+		n = (target.Unix() - start.Unix()) / (7 * 86400)
+	case FreqDaily:
+		n = (target.Unix() - start.Unix()) / 86400
+	case FreqHourly:
+		n = (target.Unix() - start.Unix()) / 3600
+	case FreqMinutely:
+		n = (target.Unix() - start.Unix()) / 60
+	case FreqSecondly:
+		n = target.Unix() - start.Unix()
+	}
+	k := int(n/int64(interval)) - 1
+	if k < 0 {
+		return 0
+	}
+	return k
+}
+
+// wallClock returns the wall clock of t as a UTC time
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+func sortedOrDefault(l []int, v int) []int {
+	if len(l) == 0 {
+		return []int{v}
+	}
+	s := make([]int, len(l))
+	copy(s, l)
+	sort.Ints(s)
+	return s
+}
+
+func containsInt(l []int, v int) bool {
+	for _, x := range l {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSigned reports if v (1-based, out of n) is listed in l, where
+// negative values in l count from the end
+func matchesSigned(l []int, v, n int) bool {
+	for _, x := range l {
+		if x == v || (x < 0 && n+x+1 == v) {
+			return true
+		}
+	}
+	return false
+}
+
+func selectSetPos(candidates []time.Time, setpos []int) []time.Time {
+	n := len(candidates)
+	var l []time.Time
+	for _, pos := range setpos {
+		i := pos - 1
+		if pos < 0 {
+			i = n + pos
+		}
+		if i < 0 || i >= n {
+			continue
+		}
+		l = append(l, candidates[i])
+	}
+
+	sort.Slice(l, func(i, j int) bool { return l[i].Before(l[j]) })
+	uniq := l[:0]
+	for i, t := range l {
+		if i > 0 && t.Equal(l[i-1]) {
+			continue
+		}
+		uniq = append(uniq, t)
+	}
+	return uniq
+}
+
+// weekOneStart returns the first day of week 1 of the given year: the
+// first week that contains at least four days of that year
+func weekOneStart(year int, wkst time.Weekday) time.Time {
+	jan1 := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	offset := (int(jan1.Weekday()) - int(wkst) + 7) % 7
+	start := jan1.AddDate(0, 0, -offset)
+	if 7-offset < 4 {
+		start = start.AddDate(0, 0, 7)
+	}
+	return start
+}
+
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a) / (24 * time.Hour))
+}
+
+type dayFilter struct {
+	freq       Frequency
+	wkst       time.Weekday
+	byMonth    []int
+	byWeekNo   []int
+	byYearDay  []int
+	byMonthDay []int
+	byDay      []WeekdayNum
+}
+
+// match reports if the day d passes all day-level rule parts. year is
+// the year of the period for yearly rules, and is used to compute week
+// numbers of days that belong to a neighbouring year
+func (f *dayFilter) match(d time.Time, year int) bool {
+	if len(f.byMonth) > 0 && !containsInt(f.byMonth, int(d.Month())) {
+		return false
+	}
+
+	if len(f.byWeekNo) > 0 {
+		if year == 0 {
+			year = d.Year()
+			if !d.Before(weekOneStart(year+1, f.wkst)) {
+				year++
+			} else if d.Before(weekOneStart(year, f.wkst)) {
+				year--
+			}
+		}
+		first := weekOneStart(year, f.wkst)
+		weeks := daysBetween(first, weekOneStart(year+1, f.wkst)) / 7
+		if !matchesSigned(f.byWeekNo, daysBetween(first, d)/7+1, weeks) {
+			return false
+		}
+	}
+
+	if len(f.byYearDay) > 0 {
+		jan1 := time.Date(d.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		if !matchesSigned(f.byYearDay, d.YearDay(), daysBetween(jan1, jan1.AddDate(1, 0, 0))) {
+			return false
+		}
+	}
+
+	if len(f.byMonthDay) > 0 {
+		first := time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if !matchesSigned(f.byMonthDay, d.Day(), daysBetween(first, first.AddDate(0, 1, 0))) {
+			return false
+		}
+	}
+
+	if len(f.byDay) > 0 {
+		wd := weekdayOf(d.Weekday())
+		// ordinals are only meaningful for monthly and yearly rules
+		ordinals := f.freq == FreqMonthly || (f.freq == FreqYearly && len(f.byWeekNo) == 0)
+
+		var matched bool
+		for _, bd := range f.byDay {
+			if bd.Weekday != wd {
+				continue
+			}
+			if bd.Ordinal == 0 || !ordinals {
+				matched = true
+				break
+			}
+
+			var first, last time.Time
+			if f.freq == FreqMonthly || len(f.byMonth) > 0 {
+				first = time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+				last = first.AddDate(0, 1, -1)
+			} else {
+				first = time.Date(d.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+				last = first.AddDate(1, 0, -1)
+			}
+			if bd.Ordinal == daysBetween(first, d)/7+1 || bd.Ordinal == -(daysBetween(d, last)/7+1) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
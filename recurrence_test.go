@@ -0,0 +1,238 @@
+package ical_test
+
+import (
+	"testing"
+	"time"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOccurrences(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if !assert.NoError(t, err, `time.LoadLocation should succeed`) {
+		return
+	}
+
+	// examples from RFC 5545 3.8.5.3
+	testcases := []struct {
+		Name   string
+		Start  string
+		Rules  []string
+		ExDate string
+		Expect []string
+	}{
+		{
+			Name:   "daily for 10 occurrences",
+			Start:  "19970902T090000",
+			Rules:  []string{"FREQ=DAILY;COUNT=10"},
+			Expect: []string{"19970902", "19970903", "19970904", "19970905", "19970906", "19970907", "19970908", "19970909", "19970910", "19970911"},
+		},
+		{
+			Name:   "monthly on the first friday for 10 occurrences",
+			Start:  "19970905T090000",
+			Rules:  []string{"FREQ=MONTHLY;COUNT=10;BYDAY=1FR"},
+			Expect: []string{"19970905", "19971003", "19971107", "19971205", "19980102", "19980206", "19980306", "19980403", "19980501", "19980605"},
+		},
+		{
+			Name:   "last work day of the month",
+			Start:  "19970930T090000",
+			Rules:  []string{"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=7"},
+			Expect: []string{"19970930", "19971031", "19971128", "19971231", "19980130", "19980227", "19980331"},
+		},
+		{
+			Name:   "monday of week number 20",
+			Start:  "19970512T090000",
+			Rules:  []string{"FREQ=YEARLY;BYWEEKNO=20;BYDAY=MO;COUNT=3"},
+			Expect: []string{"19970512", "19980511", "19990517"},
+		},
+		{
+			Name:   "week start monday",
+			Start:  "19970805T090000",
+			Rules:  []string{"FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO"},
+			Expect: []string{"19970805", "19970810", "19970819", "19970824"},
+		},
+		{
+			Name:   "week start sunday",
+			Start:  "19970805T090000",
+			Rules:  []string{"FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU"},
+			Expect: []string{"19970805", "19970817", "19970819", "19970831"},
+		},
+		{
+			Name:   "every friday the 13th",
+			Start:  "19970902T090000",
+			Rules:  []string{"FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13"},
+			ExDate: "19970902T090000",
+			Expect: []string{"19980213", "19980313", "19981113", "19990813", "20001013"},
+		},
+		{
+			Name:   "every 20th monday of the year",
+			Start:  "19970519T090000",
+			Rules:  []string{"FREQ=YEARLY;BYDAY=20MO;COUNT=3"},
+			Expect: []string{"19970519", "19980518", "19990517"},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			e := ical.NewEvent()
+			e.AddProperty("dtstart", tc.Start, ical.WithParameters(ical.Parameters{"TZID": []string{"America/New_York"}}))
+			for _, rule := range tc.Rules {
+				e.AddProperty("rrule", rule, ical.WithForce(true))
+			}
+			if tc.ExDate != "" {
+				e.AddProperty("exdate", tc.ExDate, ical.WithForce(true), ical.WithParameters(ical.Parameters{"TZID": []string{"America/New_York"}}))
+			}
+
+			start := time.Date(1997, 1, 1, 0, 0, 0, 0, ny)
+			end := time.Date(2001, 1, 1, 0, 0, 0, 0, ny)
+			list, err := e.Occurrences(start, end)
+			if !assert.NoError(t, err, `Occurrences should succeed`) {
+				return
+			}
+
+			var got []string
+			for _, o := range list {
+				if !assert.Equal(t, 9, o.Start.Hour(), `hour should be preserved`) {
+					return
+				}
+				got = append(got, o.Start.Format("20060102"))
+			}
+			if !assert.Equal(t, tc.Expect, got, `occurrences should match`) {
+				return
+			}
+		})
+	}
+}
+
+func TestOccurrencesNominalDuration(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if !assert.NoError(t, err, `time.LoadLocation should succeed`) {
+		return
+	}
+
+	// daylight saving time starts on 2020-03-08, so P1D lasts 23 hours
+	// and PT24H ends an hour later on the wall clock
+	for _, tc := range []struct {
+		Duration string
+		Expect   time.Time
+	}{
+		{"P1D", time.Date(2020, 3, 8, 12, 0, 0, 0, ny)},
+		{"PT24H", time.Date(2020, 3, 8, 13, 0, 0, 0, ny)},
+		{"P1DT1H", time.Date(2020, 3, 8, 13, 0, 0, 0, ny)},
+	} {
+		e := ical.NewEvent()
+		e.AddProperty("dtstart", "20200307T120000", ical.WithParameters(ical.Parameters{"TZID": []string{"America/New_York"}}))
+		e.AddProperty("duration", tc.Duration)
+
+		list, err := e.Occurrences(time.Date(2020, 3, 1, 0, 0, 0, 0, ny), time.Date(2020, 3, 31, 0, 0, 0, 0, ny))
+		if !assert.NoError(t, err, `Occurrences should succeed`) {
+			return
+		}
+		if !assert.Len(t, list, 1, `there should be one occurrence`) {
+			return
+		}
+		if !assert.True(t, tc.Expect.Equal(list[0].End), `end should be %s for %s, got %s`, tc.Expect, tc.Duration, list[0].End) {
+			return
+		}
+	}
+
+	e := ical.NewEvent()
+	e.AddProperty("dtstart", "20200307", ical.WithParameters(ical.Parameters{"VALUE": []string{"DATE"}}))
+	e.AddProperty("dtend", "20200309", ical.WithParameters(ical.Parameters{"VALUE": []string{"DATE"}}))
+	e.AddProperty("rrule", "FREQ=WEEKLY;COUNT=2")
+	list, err := e.Occurrences(time.Date(2020, 3, 1, 0, 0, 0, 0, ny), time.Date(2020, 3, 31, 0, 0, 0, 0, ny))
+	if !assert.NoError(t, err, `Occurrences should succeed`) {
+		return
+	}
+	if !assert.Len(t, list, 2, `there should be two occurrences`) {
+		return
+	}
+	if !assert.True(t, time.Date(2020, 3, 16, 0, 0, 0, 0, ny).Equal(list[1].End), `all-day span should be counted in days, got %s`, list[1].End) {
+		return
+	}
+}
+
+func TestOccurrencesOverride(t *testing.T) {
+	c := ical.New()
+
+	master := ical.NewEvent()
+	master.AddProperty("uid", "weekly@example.com")
+	master.AddProperty("dtstart", "20200106T100000Z")
+	master.AddProperty("dtend", "20200106T110000Z")
+	master.AddProperty("rrule", "FREQ=WEEKLY;COUNT=4", ical.WithForce(true))
+	c.AddEntry(master)
+
+	moved := ical.NewEvent()
+	moved.AddProperty("uid", "weekly@example.com")
+	moved.AddProperty("recurrence-id", "20200113T100000Z")
+	moved.AddProperty("dtstart", "20200114T150000Z")
+	moved.AddProperty("dtend", "20200114T153000Z")
+	moved.AddProperty("summary", "moved")
+	c.AddEntry(moved)
+
+	list, err := c.Occurrences(time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC), time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC))
+	if !assert.NoError(t, err, `Occurrences should succeed`) {
+		return
+	}
+
+	if !assert.Len(t, list, 3, `there should be 3 occurrences in the window`) {
+		return
+	}
+
+	if !assert.Equal(t, time.Date(2020, 1, 14, 15, 0, 0, 0, time.UTC), list[0].Start, `override start should be used`) {
+		return
+	}
+	if !assert.Equal(t, 30*time.Minute, list[0].End.Sub(list[0].Start), `override duration should be used`) {
+		return
+	}
+	if !assert.Equal(t, time.Date(2020, 1, 13, 10, 0, 0, 0, time.UTC), list[0].RecurrenceID, `recurrence id should be the original start`) {
+		return
+	}
+	if !assert.Equal(t, moved, list[0].Entry, `entry should be the override`) {
+		return
+	}
+	if !assert.Equal(t, master, list[1].Entry, `entry should be the master`) {
+		return
+	}
+}
+
+func TestOccurrencesFarFromStart(t *testing.T) {
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	for _, tc := range []struct {
+		Name     string
+		Rule     string
+		Duration string
+		Expect   int
+		Error    bool
+	}{
+		{Name: "minutely without count", Rule: "FREQ=MINUTELY", Duration: "PT1M", Expect: 60},
+		{Name: "instances starting before the window", Rule: "FREQ=DAILY", Duration: "P3D", Expect: 3},
+		{Name: "daily with count", Rule: "FREQ=DAILY;COUNT=10000", Duration: "PT1H", Expect: 1},
+		{Name: "daily with exhausted count", Rule: "FREQ=DAILY;COUNT=9000", Duration: "PT1H", Expect: 0},
+		{Name: "minutely with count", Rule: "FREQ=MINUTELY;COUNT=100000000", Duration: "PT1M", Error: true},
+	} {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			e := ical.NewEvent()
+			e.AddProperty("dtstart", "20000101T100000Z")
+			e.AddProperty("duration", tc.Duration)
+			e.AddProperty("rrule", tc.Rule, ical.WithForce(true))
+
+			list, err := e.Occurrences(start, end)
+			if tc.Error {
+				assert.Error(t, err, `Occurrences should fail`)
+				return
+			}
+			if !assert.NoError(t, err, `Occurrences should succeed`) {
+				return
+			}
+			if !assert.Len(t, list, tc.Expect, `occurrences should match`) {
+				return
+			}
+		})
+	}
+}
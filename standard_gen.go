@@ -50,11 +50,14 @@ func (v *Standard) Properties() <-chan *Property {
 
 func (v *Standard) AddProperty(key, value string, options ...PropertyOption) error {
 	var params Parameters
+	var values []string
 	var force bool
 	for _, option := range options {
 		switch option.Name() {
 		case "Parameters":
 			params = option.Get().(Parameters)
+		case "Values":
+			values = option.Get().([]string)
 		case "Force":
 			force = option.Get().(bool)
 		}
@@ -62,12 +65,12 @@ func (v *Standard) AddProperty(key, value string, options ...PropertyOption) err
 
 	switch key = strings.ToLower(key); key {
 	case "dtstart", "tzoffsetto", "tzoffsetfrom":
-		v.props.Set(NewProperty(key, value, params))
+		v.props.Set(newProperty(key, value, params, values))
 	case "comment", "rdate", "rrule", "tzname":
-		v.props.Append(NewProperty(key, value, params))
+		v.props.Append(newProperty(key, value, params, values))
 	default:
 		if strings.HasPrefix(key, "x-") || force {
-			v.props.Append(NewProperty(key, value, params))
+			v.props.Append(newProperty(key, value, params, values))
 		} else {
 			return errors.Errorf(`invalid property %s`, key)
 		} /* end if */
@@ -82,3 +85,19 @@ func (v *Standard) MarshalJSON() ([]byte, error) {
 	}
 	return dst.Bytes(), nil
 }
+
+// Validate reports the violations of RFC 5545 found in the component
+// and its sub-components as ValidationErrors
+func (v *Standard) Validate() error {
+	return validateEntry(v)
+}
+
+func (v *Standard) validateProperties(path string) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range []string{"dtstart", "tzoffsetto", "tzoffsetfrom"} {
+		if _, ok := v.props.GetFirst(name); !ok {
+			errs = append(errs, newValidationError(path, name, `missing mandatory property`))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,97 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+)
+
+// textListProperties lists the properties whose value is a comma
+// separated list of TEXT values
+var textListProperties = map[string]struct{}{
+	"categories": {},
+	"resources":  {},
+}
+
+// structuredTextProperties lists the TEXT properties whose value is
+// made of semicolon separated components. Their values are kept in
+// their escaped form, as the components can not be told apart otherwise
+var structuredTextProperties = map[string]struct{}{
+	"request-status": {},
+}
+
+func isStructuredText(name string) bool {
+	_, ok := structuredTextProperties[name]
+	return ok
+}
+
+func isTextList(name string) bool {
+	_, ok := textListProperties[name]
+	return ok
+}
+
+// escapeText writes s to buf, escaping it as a TEXT value
+// (RFC 5545 section 3.3.11)
+func escapeText(buf *bytes.Buffer, s string) {
+	for i := 0; len(s) > i; i++ {
+		switch c := s[i]; c {
+		case ';', ',', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\x0d':
+			if len(s) > i+1 && s[i+1] == '\x0a' {
+				i++
+			}
+			buf.WriteString("\\n")
+		case '\x0a':
+			buf.WriteString("\\n")
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// unescapeText decodes an escaped TEXT value. Unknown escape sequences
+// are kept as is
+func unescapeText(s string) string {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i == len(s)-1 {
+			buf.WriteByte(c)
+			continue
+		}
+
+		i++
+		switch c = s[i]; c {
+		case 'n', 'N':
+			buf.WriteByte('\n')
+		case ',', ';', '\\':
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
+
+// splitText splits an escaped TEXT list on its unescaped commas, and
+// decodes each element
+func splitText(s string) []string {
+	var l []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			l = append(l, unescapeText(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(l, unescapeText(s[start:]))
+}
@@ -0,0 +1,691 @@
+package ical
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// timezoneExpansionEnd is the point up to which recurring STANDARD and
+// DAYLIGHT onsets are expanded. Times after the last expanded transition
+// keep the offset in effect at that point
+var timezoneExpansionEnd = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// zoneType is an offset observed by a timezone
+type zoneType struct {
+	name   string
+	offset int
+	isDST  bool
+}
+
+// zoneTransition is the instant at which a timezone switches to a new
+// offset
+type zoneTransition struct {
+	when int64
+	zone zoneType
+}
+
+// Location builds a location from the STANDARD and DAYLIGHT observances
+// of the timezone. The location is named after its TZID
+func (v *Timezone) Location() (*time.Location, error) {
+	var tzid string
+	if p, ok := v.GetProperty("tzid"); ok {
+		tzid = p.RawValue()
+	}
+	if tzid == "" {
+		return nil, errors.New(`timezone has no TZID`)
+	}
+
+	var transitions []zoneTransition
+	var initial []zoneTransition
+	for e := range v.Entries() {
+		var isDST bool
+		switch e.(type) {
+		case *Standard:
+		case *Daylight:
+			isDST = true
+		default:
+			continue
+		}
+
+		l, from, err := observanceTransitions(e, isDST)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to expand %s of timezone %s`, e.Type(), tzid)
+		}
+		if len(l) == 0 {
+			continue
+		}
+		transitions = append(transitions, l...)
+		initial = append(initial, zoneTransition{when: l[0].when, zone: from})
+	}
+
+	if len(transitions) == 0 {
+		return nil, errors.Errorf(`timezone %s has no observances`, tzid)
+	}
+
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].when < transitions[j].when
+	})
+
+	// the offset in effect before the first transition is the
+	// TZOFFSETFROM of the earliest observance. Reuse the name of an
+	// observance with that offset if there is one
+	sort.SliceStable(initial, func(i, j int) bool {
+		return initial[i].when < initial[j].when
+	})
+	first := initial[0].zone
+	for _, t := range transitions {
+		if t.zone.offset == first.offset {
+			first = t.zone
+			break
+		}
+	}
+
+	data, err := makeTZData(first, transitions)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to build timezone %s`, tzid)
+	}
+
+	loc, err := time.LoadLocationFromTZData(tzid, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to load timezone %s`, tzid)
+	}
+	return loc, nil
+}
+
+// observanceTransitions expands the onsets of a STANDARD or DAYLIGHT
+// observance. It also returns the zone described by its TZOFFSETFROM
+func observanceTransitions(e Entry, isDST bool) ([]zoneTransition, zoneType, error) {
+	var from, to zoneType
+	for _, v := range []struct {
+		name string
+		dst  *zoneType
+	}{{"tzoffsetfrom", &from}, {"tzoffsetto", &to}} {
+		p, ok := e.GetProperty(v.name)
+		if !ok {
+			return nil, zoneType{}, errors.Errorf(`missing %s`, v.name)
+		}
+		d, err := parseUTCOffset(p.RawValue())
+		if err != nil {
+			return nil, zoneType{}, errors.Wrapf(err, `invalid %s`, v.name)
+		}
+		v.dst.offset = int(d / time.Second)
+		v.dst.name = formatUTCOffset(d)
+	}
+	to.isDST = isDST
+	if p, ok := e.GetProperty("tzname"); ok && p.RawValue() != "" {
+		to.name = p.RawValue()
+	}
+
+	p, ok := e.GetProperty("dtstart")
+	if !ok {
+		return nil, zoneType{}, errors.New(`missing dtstart`)
+	}
+
+	// onsets are local times, expressed in the offset that was in
+	// effect before the transition. They are expanded as wall clock
+	// values in UTC and then shifted by TZOFFSETFROM
+	dtstart, _, err := parseDateTime(p.RawValue(), time.UTC)
+	if err != nil {
+		return nil, zoneType{}, errors.Wrap(err, `invalid dtstart`)
+	}
+
+	onsets := make(map[int64]struct{})
+	add := func(t time.Time, utc bool) {
+		when := t.Unix()
+		if !utc {
+			when -= int64(from.offset)
+		}
+		onsets[when] = struct{}{}
+	}
+
+	add(dtstart, false)
+	for _, p := range entryProperties(e, "rrule") {
+		r, err := ParseRecur(p.RawValue())
+		if err != nil {
+			return nil, zoneType{}, errors.Wrap(err, `failed to parse rrule`)
+		}
+		if !r.Until.IsZero() && !r.untilDate && !r.untilFloating {
+			// UNTIL is given in UTC, but the expansion works on wall
+			// clock values
+			r.Until = r.Until.Add(time.Duration(from.offset) * time.Second)
+		}
+		err = r.iterate(dtstart, time.Time{}, timezoneExpansionEnd, func(t time.Time) bool {
+			add(t, false)
+			return true
+		})
+		if err != nil {
+			return nil, zoneType{}, errors.Wrap(err, `failed to expand rrule`)
+		}
+	}
+
+	for _, p := range entryProperties(e, "rdate") {
+		for _, s := range strings.Split(p.RawValue(), ",") {
+			t, _, err := parseDateTime(s, time.UTC)
+			if err != nil {
+				return nil, zoneType{}, errors.Wrap(err, `failed to parse rdate`)
+			}
+			add(t, strings.HasSuffix(s, "Z"))
+		}
+	}
+
+	l := make([]zoneTransition, 0, len(onsets))
+	for when := range onsets {
+		l = append(l, zoneTransition{when: when, zone: to})
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].when < l[j].when
+	})
+	return l, from, nil
+}
+
+// makeTZData encodes the transitions in the TZif format (RFC 8536), as
+// understood by time.LoadLocationFromTZData
+func makeTZData(first zoneType, transitions []zoneTransition) ([]byte, error) {
+	// type 0 is the zone in effect before the first transition, and is
+	// never the target of a transition
+	types := []zoneType{first}
+	indices := make(map[zoneType]uint8)
+	var idx []uint8
+	for _, t := range transitions {
+		i, ok := indices[t.zone]
+		if !ok {
+			if len(types) > math.MaxUint8 {
+				return nil, errors.New(`too many distinct offsets`)
+			}
+			i = uint8(len(types))
+			indices[t.zone] = i
+			types = append(types, t.zone)
+		}
+		idx = append(idx, i)
+	}
+
+	var chars []byte
+	desig := make(map[string]uint8)
+	for _, z := range types {
+		if _, ok := desig[z.name]; ok {
+			continue
+		}
+		if len(chars)+len(z.name) > math.MaxUint8 {
+			return nil, errors.New(`too many distinct zone names`)
+		}
+		desig[z.name] = uint8(len(chars))
+		chars = append(chars, z.name...)
+		chars = append(chars, 0)
+	}
+
+	var buf bytes.Buffer
+	writeBlock := func(wide bool) {
+		var times []int64
+		var tidx []uint8
+		for i, t := range transitions {
+			if !wide && (t.when < math.MinInt32 || t.when > math.MaxInt32) {
+				continue
+			}
+			times = append(times, t.when)
+			tidx = append(tidx, idx[i])
+		}
+
+		buf.WriteString("TZif2")
+		buf.Write(make([]byte, 15))
+		// isutcnt, isstdcnt, leapcnt, timecnt, typecnt, charcnt
+		for _, n := range []int{0, 0, 0, len(times), len(types), len(chars)} {
+			binary.Write(&buf, binary.BigEndian, uint32(n))
+		}
+		for _, t := range times {
+			if wide {
+				binary.Write(&buf, binary.BigEndian, t)
+			} else {
+				binary.Write(&buf, binary.BigEndian, int32(t))
+			}
+		}
+		buf.Write(tidx)
+		for _, z := range types {
+			binary.Write(&buf, binary.BigEndian, int32(z.offset))
+			if z.isDST {
+				buf.WriteByte(1)
+			} else {
+				buf.WriteByte(0)
+			}
+			buf.WriteByte(desig[z.name])
+		}
+		buf.Write(chars)
+	}
+
+	writeBlock(false)
+	writeBlock(true)
+	// empty footer: no rule applies past the last transition
+	buf.WriteString("\n\n")
+	return buf.Bytes(), nil
+}
+
+// Location returns the location for tzid. VTIMEZONE components of the
+// calendar take precedence, so that TZIDs which are not names in the
+// IANA database can be resolved. Otherwise the TZID is looked up with
+// time.LoadLocation
+func (v *Calendar) Location(tzid string) (*time.Location, error) {
+	for e := range v.Entries() {
+		tz, ok := e.(*Timezone)
+		if !ok {
+			continue
+		}
+		if p, ok := tz.GetProperty("tzid"); ok && p.RawValue() == tzid {
+			return tz.Location()
+		}
+	}
+	return loadLocation(tzid)
+}
+
+// resolver returns a locationResolver that resolves TZIDs using
+// Calendar.Location, building each location only once
+func (v *Calendar) resolver() locationResolver {
+	cache := make(map[string]*time.Location)
+	return func(tzid string) (*time.Location, error) {
+		if loc, ok := cache[tzid]; ok {
+			return loc, nil
+		}
+		loc, err := v.Location(tzid)
+		if err != nil {
+			return nil, err
+		}
+		cache[tzid] = loc
+		return loc, nil
+	}
+}
+
+// NewTimezoneFromLocation builds a VTIMEZONE describing the offsets that
+// loc observes between start and end. Transitions that follow a yearly
+// pattern are described with an RRULE, others are listed as RDATEs. A
+// pattern that is still in effect at end is left open ended
+func NewTimezoneFromLocation(loc *time.Location, start, end time.Time) (*Timezone, error) {
+	if loc == nil {
+		return nil, errors.New(`location must not be nil`)
+	}
+	if !start.Before(end) {
+		return nil, errors.New(`start must be before end`)
+	}
+
+	tz := NewTimezone()
+	if err := tz.AddProperty("tzid", loc.String()); err != nil {
+		return nil, errors.Wrap(err, `failed to add tzid`)
+	}
+
+	transitions := locationTransitions(loc, start, end)
+	if len(transitions) == 0 {
+		// a single observance covers the whole range
+		name, offset := start.In(loc).Zone()
+		std := NewStandard()
+		if err := addObservanceProperties(std, wallClock(start.In(loc)), offset, offset, name); err != nil {
+			return nil, err
+		}
+		tz.AddEntry(std)
+		return tz, nil
+	}
+
+	type group struct {
+		isDST    bool
+		from, to int
+		name     string
+		onsets   []time.Time
+		instants []time.Time
+	}
+
+	var groups []*group
+	byKey := make(map[string]*group)
+	for _, t := range transitions {
+		key := fmt.Sprintf("%t/%d/%d/%s", t.isDST, t.from, t.to, t.name)
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{isDST: t.isDST, from: t.from, to: t.to, name: t.name}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.onsets = append(g.onsets, t.at.Add(time.Duration(t.from)*time.Second).UTC())
+		g.instants = append(g.instants, t.at)
+	}
+
+	endWall := wallClock(end.In(loc))
+	for _, g := range groups {
+		var rdates []time.Time
+		for i := 0; i < len(g.onsets); {
+			n, rule := yearlyRun(g.onsets[i:])
+			last := g.onsets[i+n-1]
+			openEnded := rule != nil && i+n == len(g.onsets) && rule.next(last.Year()+1).After(endWall) && rule.verify(loc, last.Year()+1, g.from, g.to)
+			if rule == nil || (n < 2 && !openEnded) {
+				rdates = append(rdates, g.onsets[i])
+				i++
+				continue
+			}
+
+			if !openEnded {
+				rule.recur.Until = g.instants[i+n-1]
+			}
+			o := newObservance(g.isDST)
+			if err := addObservanceProperties(o, g.onsets[i], g.from, g.to, g.name); err != nil {
+				return nil, err
+			}
+			if err := o.AddProperty("rrule", rule.recur.String()); err != nil {
+				return nil, errors.Wrap(err, `failed to add rrule`)
+			}
+			tz.AddEntry(o)
+			i += n
+		}
+
+		if len(rdates) == 0 {
+			continue
+		}
+		o := newObservance(g.isDST)
+		if err := addObservanceProperties(o, rdates[0], g.from, g.to, g.name); err != nil {
+			return nil, err
+		}
+		for _, t := range rdates[1:] {
+			if err := o.AddProperty("rdate", t.Format(dateTimeFormat)); err != nil {
+				return nil, errors.Wrap(err, `failed to add rdate`)
+			}
+		}
+		tz.AddEntry(o)
+	}
+	return tz, nil
+}
+
+func newObservance(isDST bool) Entry {
+	if isDST {
+		return NewDaylight()
+	}
+	return NewStandard()
+}
+
+func addObservanceProperties(e Entry, onset time.Time, from, to int, name string) error {
+	for _, p := range []struct{ name, value string }{
+		{"dtstart", onset.Format(dateTimeFormat)},
+		{"tzoffsetfrom", formatUTCOffset(time.Duration(from) * time.Second)},
+		{"tzoffsetto", formatUTCOffset(time.Duration(to) * time.Second)},
+		{"tzname", name},
+	} {
+		if err := e.AddProperty(p.name, p.value); err != nil {
+			return errors.Wrapf(err, `failed to add %s`, p.name)
+		}
+	}
+	return nil
+}
+
+// locationTransition is an offset change observed by a location
+type locationTransition struct {
+	at       time.Time
+	from, to int
+	name     string
+	isDST    bool
+}
+
+// locationTransitions finds the offset changes of loc in [start, end).
+// The range is probed daily, and each change is then located to the
+// second with a binary search
+func locationTransitions(loc *time.Location, start, end time.Time) []locationTransition {
+	zoneAt := func(t time.Time) (string, int) {
+		return t.In(loc).Zone()
+	}
+
+	var l []locationTransition
+	prev := start
+	prevName, prevOffset := zoneAt(prev)
+	for prev.Before(end) {
+		next := prev.Add(24 * time.Hour)
+		if next.After(end) {
+			next = end
+		}
+		name, offset := zoneAt(next)
+		if name == prevName && offset == prevOffset {
+			prev = next
+			continue
+		}
+
+		lo, hi := prev, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2).Truncate(time.Second)
+			if n, o := zoneAt(mid); n == prevName && o == prevOffset {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		name, offset = zoneAt(hi)
+		l = append(l, locationTransition{
+			at:    hi.UTC(),
+			from:  prevOffset,
+			to:    offset,
+			name:  name,
+			isDST: isDaylightOffset(loc, hi, offset),
+		})
+		prev, prevName, prevOffset = hi, name, offset
+	}
+	return l
+}
+
+// isDaylightOffset reports if offset is a daylight saving offset, that
+// is, larger than the smallest offset observed in the year around t
+func isDaylightOffset(loc *time.Location, t time.Time, offset int) bool {
+	std := offset
+	for _, m := range []time.Month{time.January, time.July} {
+		_, o := time.Date(t.Year(), m, 1, 0, 0, 0, 0, loc).Zone()
+		if o < std {
+			std = o
+		}
+	}
+	return offset > std
+}
+
+// yearlyTransitionRule is an onset that happens on the same weekday of
+// the same month at the same time every year. ordinals holds the BYDAY
+// ordinals that all onsets so far agree with, and wdn the one in use
+type yearlyTransitionRule struct {
+	recur    *Recur
+	wdn      WeekdayNum
+	ordinals []int
+	month    time.Month
+	clock    time.Duration
+}
+
+// next returns the onset of the rule in the given year
+func (r *yearlyTransitionRule) next(year int) time.Time {
+	return nthWeekday(year, r.month, r.wdn).Add(r.clock)
+}
+
+// ruleProbeYears is the number of years after the generated range that
+// an open ended rule is checked against the location for
+const ruleProbeYears = 10
+
+// verify checks the rule against loc for the years following the
+// onsets it was derived from. Onsets that are both the 4th and the last
+// weekday of the month in every year sampled match two ordinals, so the
+// first one that loc agrees with is chosen. It reports false if loc
+// agrees with none, in which case the rule must not be left open ended
+func (r *yearlyTransitionRule) verify(loc *time.Location, year, from, to int) bool {
+	for _, ordinal := range r.ordinals {
+		r.wdn.Ordinal = ordinal
+		if r.holds(loc, year, from, to) {
+			r.recur.ByDay = []WeekdayNum{r.wdn}
+			return true
+		}
+	}
+	r.wdn.Ordinal = r.ordinals[0]
+	return false
+}
+
+// holds reports if loc changes its offset from from to to at each onset
+// of the rule within ruleProbeYears years starting at year
+func (r *yearlyTransitionRule) holds(loc *time.Location, year, from, to int) bool {
+	for y := year; y < year+ruleProbeYears; y++ {
+		at := r.next(y).Add(-time.Duration(from) * time.Second)
+		if _, o := at.Add(-time.Second).In(loc).Zone(); o != from {
+			return false
+		}
+		if _, o := at.In(loc).Zone(); o != to {
+			return false
+		}
+	}
+	return true
+}
+
+// yearlyRun returns the length of the longest run of onsets, starting
+// at the first one, that happen in consecutive years according to the
+// same yearly rule
+func yearlyRun(onsets []time.Time) (int, *yearlyTransitionRule) {
+	first := onsets[0]
+	clock := first.Sub(first.Truncate(24 * time.Hour))
+	candidates := onsetOrdinals(first)
+
+	n := 1
+	for ; n < len(onsets) && len(candidates) > 0; n++ {
+		t := onsets[n]
+		if t.Year() != first.Year()+n || t.Month() != first.Month() || t.Weekday() != first.Weekday() || t.Sub(t.Truncate(24*time.Hour)) != clock {
+			break
+		}
+		var common []int
+		for _, o := range onsetOrdinals(t) {
+			if containsInt(candidates, o) {
+				common = append(common, o)
+			}
+		}
+		if len(common) == 0 {
+			break
+		}
+		candidates = common
+	}
+	if len(candidates) == 0 {
+		return 1, nil
+	}
+
+	wdn := WeekdayNum{Ordinal: candidates[0], Weekday: weekdayOf(first.Weekday())}
+	return n, &yearlyTransitionRule{
+		recur: &Recur{
+			Freq:     FreqYearly,
+			ByMonth:  []int{int(first.Month())},
+			ByDay:    []WeekdayNum{wdn},
+			Interval: 1,
+		},
+		wdn:      wdn,
+		ordinals: candidates,
+		month:    first.Month(),
+		clock:    clock,
+	}
+}
+
+// onsetOrdinals returns the BYDAY ordinals that describe the weekday of
+// t within its month: -1 if it is the last such weekday, which is
+// preferred as it is the more common rule, and its position from the
+// start (1 to 4)
+func onsetOrdinals(t time.Time) []int {
+	var l []int
+	if t.AddDate(0, 0, 7).Month() != t.Month() {
+		l = append(l, -1)
+	}
+	if n := (t.Day()-1)/7 + 1; n <= 4 {
+		l = append(l, n)
+	}
+	return l
+}
+
+// nthWeekday returns the day described by wdn within the month, as a
+// wall clock value in UTC
+func nthWeekday(year int, month time.Month, wdn WeekdayNum) time.Time {
+	wd := wdn.Weekday.TimeWeekday()
+	if wdn.Ordinal < 0 {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+		return last.AddDate(0, 0, -((int(last.Weekday()) - int(wd) + 7) % 7))
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return first.AddDate(0, 0, (int(wd)-int(first.Weekday())+7)%7+(wdn.Ordinal-1)*7)
+}
+
+// missingTimezones generates a VTIMEZONE for each TZID that is
+// referenced in the calendar, but has no VTIMEZONE of its own. TZIDs
+// that can not be resolved are skipped
+func missingTimezones(c *Calendar) ([]*Timezone, error) {
+	defined := make(map[string]struct{})
+	type span struct {
+		min, max time.Time
+	}
+	referenced := make(map[string]*span)
+
+	var walk func(Entry)
+	walk = func(e Entry) {
+		if tz, ok := e.(*Timezone); ok {
+			if p, ok := tz.GetProperty("tzid"); ok {
+				defined[p.RawValue()] = struct{}{}
+			}
+			return
+		}
+
+		for p := range e.Properties() {
+			tzid, ok := p.params.Get("TZID")
+			if !ok {
+				continue
+			}
+			s, ok := referenced[tzid]
+			if !ok {
+				s = &span{}
+				referenced[tzid] = s
+			}
+			for _, v := range strings.Split(p.RawValue(), ",") {
+				if i := strings.IndexByte(v, '/'); i > -1 {
+					v = v[:i]
+				}
+				t, _, err := parseDateTime(v, time.UTC)
+				if err != nil {
+					continue
+				}
+				if s.min.IsZero() || t.Before(s.min) {
+					s.min = t
+				}
+				if s.max.IsZero() || t.After(s.max) {
+					s.max = t
+				}
+			}
+		}
+		for sub := range e.Entries() {
+			walk(sub)
+		}
+	}
+	walk(c)
+
+	tzids := make([]string, 0, len(referenced))
+	for tzid := range referenced {
+		if _, ok := defined[tzid]; !ok {
+			tzids = append(tzids, tzid)
+		}
+	}
+	sort.Strings(tzids)
+
+	var l []*Timezone
+	for _, tzid := range tzids {
+		loc, err := loadLocation(tzid)
+		if err != nil {
+			continue
+		}
+
+		s := referenced[tzid]
+		if s.min.IsZero() {
+			s.min = time.Now()
+			s.max = s.min
+		}
+		start := time.Date(s.min.Year(), time.January, 1, 0, 0, 0, 0, loc)
+		end := time.Date(s.max.Year()+1, time.January, 1, 0, 0, 0, 0, loc)
+		tz, err := NewTimezoneFromLocation(loc, start, end)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to generate timezone %s`, tzid)
+		}
+		// loc may have been resolved from an alias, but the timezone
+		// must be found under the TZID that the properties refer to
+		if err := tz.AddProperty("tzid", tzid); err != nil {
+			return nil, errors.Wrapf(err, `failed to set TZID of timezone %s`, tzid)
+		}
+		l = append(l, tz)
+	}
+	return l, nil
+}
@@ -50,11 +50,14 @@ func (v *Timezone) Properties() <-chan *Property {
 
 func (v *Timezone) AddProperty(key, value string, options ...PropertyOption) error {
 	var params Parameters
+	var values []string
 	var force bool
 	for _, option := range options {
 		switch option.Name() {
 		case "Parameters":
 			params = option.Get().(Parameters)
+		case "Values":
+			values = option.Get().([]string)
 		case "Force":
 			force = option.Get().(bool)
 		}
@@ -62,10 +65,10 @@ func (v *Timezone) AddProperty(key, value string, options ...PropertyOption) err
 
 	switch key = strings.ToLower(key); key {
 	case "tzid", "last-modified", "tzurl":
-		v.props.Set(NewProperty(key, value, params))
+		v.props.Set(newProperty(key, value, params, values))
 	default:
 		if strings.HasPrefix(key, "x-") || force {
-			v.props.Append(NewProperty(key, value, params))
+			v.props.Append(newProperty(key, value, params, values))
 		} else {
 			return errors.Errorf(`invalid property %s`, key)
 		} /* end if */
@@ -80,3 +83,19 @@ func (v *Timezone) MarshalJSON() ([]byte, error) {
 	}
 	return dst.Bytes(), nil
 }
+
+// Validate reports the violations of RFC 5545 found in the component
+// and its sub-components as ValidationErrors
+func (v *Timezone) Validate() error {
+	return validateEntry(v)
+}
+
+func (v *Timezone) validateProperties(path string) ValidationErrors {
+	var errs ValidationErrors
+	for _, name := range []string{"tzid"} {
+		if _, ok := v.props.GetFirst(name); !ok {
+			errs = append(errs, newValidationError(path, name, `missing mandatory property`))
+		}
+	}
+	return errs
+}
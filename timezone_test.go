@@ -0,0 +1,334 @@
+package ical_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimezoneLocation(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VTIMEZONE`,
+		`TZID:Eastern Standard Time`,
+		`BEGIN:DAYLIGHT`,
+		`DTSTART:19870405T020000`,
+		`RRULE:FREQ=YEARLY;BYMONTH=4;BYDAY=1SU;UNTIL=20060402T070000Z`,
+		`TZNAME:EDT`,
+		`TZOFFSETFROM:-0500`,
+		`TZOFFSETTO:-0400`,
+		`END:DAYLIGHT`,
+		`BEGIN:DAYLIGHT`,
+		`DTSTART:20070311T020000`,
+		`RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU`,
+		`TZNAME:EDT`,
+		`TZOFFSETFROM:-0500`,
+		`TZOFFSETTO:-0400`,
+		`END:DAYLIGHT`,
+		`BEGIN:STANDARD`,
+		`DTSTART:19671029T020000`,
+		`RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU;UNTIL=20061029T060000Z`,
+		`TZNAME:EST`,
+		`TZOFFSETFROM:-0400`,
+		`TZOFFSETTO:-0500`,
+		`END:STANDARD`,
+		`BEGIN:STANDARD`,
+		`DTSTART:20071104T020000`,
+		`RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU`,
+		`TZNAME:EST`,
+		`TZOFFSETFROM:-0400`,
+		`TZOFFSETTO:-0500`,
+		`END:STANDARD`,
+		`END:VTIMEZONE`,
+		`BEGIN:VEVENT`,
+		`UID:weekly@example.com`,
+		`DTSTART;TZID=Eastern Standard Time:20200302T090000`,
+		`RRULE:FREQ=WEEKLY;COUNT=3`,
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+
+	loc, err := c.Location("Eastern Standard Time")
+	if !assert.NoError(t, err, `Location should succeed`) {
+		return
+	}
+	if !assert.Equal(t, "Eastern Standard Time", loc.String(), `location should be named after the TZID`) {
+		return
+	}
+
+	ny, err := time.LoadLocation("America/New_York")
+	if !assert.NoError(t, err, `time.LoadLocation should succeed`) {
+		return
+	}
+
+	for ts := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC); ts.Year() < 2030; ts = ts.Add(97 * time.Hour) {
+		name, offset := ts.In(loc).Zone()
+		nyName, nyOffset := ts.In(ny).Zone()
+		if !assert.Equal(t, nyOffset, offset, `offset at %s should match`, ts) {
+			return
+		}
+		if !assert.Equal(t, nyName, name, `zone name at %s should match`, ts) {
+			return
+		}
+	}
+
+	list, err := c.Occurrences(time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC))
+	if !assert.NoError(t, err, `Occurrences should succeed`) {
+		return
+	}
+	var got []time.Time
+	for _, o := range list {
+		got = append(got, o.Start.UTC())
+	}
+	expect := []time.Time{
+		time.Date(2020, 3, 2, 14, 0, 0, 0, time.UTC),
+		time.Date(2020, 3, 9, 13, 0, 0, 0, time.UTC),
+		time.Date(2020, 3, 16, 13, 0, 0, 0, time.UTC),
+	}
+	if !assert.Equal(t, expect, got, `occurrences should follow the VTIMEZONE`) {
+		return
+	}
+
+	if _, err := c.Location("Europe/Paris"); !assert.NoError(t, err, `unknown TZIDs should fall back to time.LoadLocation`) {
+		return
+	}
+}
+
+func TestTimezoneLocationRDate(t *testing.T) {
+	tz := ical.NewTimezone()
+	tz.AddProperty("tzid", "Custom")
+
+	std := ical.NewStandard()
+	std.AddProperty("dtstart", "20200101T000000")
+	std.AddProperty("tzoffsetfrom", "+0000")
+	std.AddProperty("tzoffsetto", "+0100")
+	std.AddProperty("rdate", "20210101T000000")
+	std.AddProperty("tzname", "CST1")
+	tz.AddEntry(std)
+
+	loc, err := tz.Location()
+	if !assert.NoError(t, err, `Location should succeed`) {
+		return
+	}
+
+	for _, tc := range []struct {
+		When   time.Time
+		Offset int
+	}{
+		{time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC), 0},
+		{time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), 3600},
+		{time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), 3600},
+	} {
+		_, offset := tc.When.In(loc).Zone()
+		if !assert.Equal(t, tc.Offset, offset, `offset at %s should match`, tc.When) {
+			return
+		}
+	}
+}
+
+func TestNewTimezoneFromLocation(t *testing.T) {
+	for _, tc := range []struct {
+		Name   string
+		Start  int
+		End    int
+		Expect []string
+	}{
+		{
+			Name:  "Europe/Berlin",
+			Start: 2000,
+			End:   2030,
+			Expect: []string{
+				"BEGIN:DAYLIGHT\r\nDTSTART:20000326T020000\r\nRRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3\r\nTZNAME:CEST\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nEND:DAYLIGHT\r\n",
+				"BEGIN:STANDARD\r\nDTSTART:20001029T030000\r\nRRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10\r\nTZNAME:CET\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\nEND:STANDARD\r\n",
+			},
+		},
+		{
+			Name:  "America/New_York",
+			Start: 1990,
+			End:   2030,
+			Expect: []string{
+				"RRULE:FREQ=YEARLY;UNTIL=20060402T070000Z;BYDAY=1SU;BYMONTH=4\r\n",
+				"RRULE:FREQ=YEARLY;BYDAY=2SU;BYMONTH=3\r\n",
+			},
+		},
+		{
+			Name:  "America/Sao_Paulo",
+			Start: 2010,
+			End:   2022,
+			Expect: []string{
+				"RDATE:",
+			},
+		},
+		{
+			Name:   "Asia/Tokyo",
+			Start:  2000,
+			End:    2010,
+			Expect: []string{"BEGIN:STANDARD\r\nDTSTART:20000101T000000\r\nTZNAME:JST\r\nTZOFFSETFROM:+0900\r\nTZOFFSETTO:+0900\r\nEND:STANDARD\r\n"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			loc, err := time.LoadLocation(tc.Name)
+			if !assert.NoError(t, err, `time.LoadLocation should succeed`) {
+				return
+			}
+
+			start := time.Date(tc.Start, 1, 1, 0, 0, 0, 0, loc)
+			end := time.Date(tc.End, 1, 1, 0, 0, 0, 0, loc)
+			tz, err := ical.NewTimezoneFromLocation(loc, start, end)
+			if !assert.NoError(t, err, `NewTimezoneFromLocation should succeed`) {
+				return
+			}
+
+			s := tz.String()
+			for _, e := range tc.Expect {
+				if !assert.Contains(t, s, e, `timezone should contain observance`) {
+					return
+				}
+			}
+
+			generated, err := tz.Location()
+			if !assert.NoError(t, err, `Location should succeed`) {
+				return
+			}
+			for ts := start; ts.Before(end); ts = ts.Add(31 * time.Hour) {
+				name, offset := ts.In(generated).Zone()
+				expectName, expectOffset := ts.In(loc).Zone()
+				if !assert.Equal(t, expectOffset, offset, `offset at %s should match`, ts) {
+					return
+				}
+				if !assert.Equal(t, expectName, name, `zone name at %s should match`, ts) {
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestNewTimezoneFromLocationOutsideRange(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if !assert.NoError(t, err, `time.LoadLocation should succeed`) {
+		return
+	}
+
+	// the onsets in 2020 are both the 4th and the last sunday of the month
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, loc)
+	end := time.Date(2021, 1, 1, 0, 0, 0, 0, loc)
+	tz, err := ical.NewTimezoneFromLocation(loc, start, end)
+	if !assert.NoError(t, err, `NewTimezoneFromLocation should succeed`) {
+		return
+	}
+	if !assert.Contains(t, tz.String(), "RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10\r\n", `rule should use the last sunday`) {
+		return
+	}
+
+	generated, err := tz.Location()
+	if !assert.NoError(t, err, `Location should succeed`) {
+		return
+	}
+	for _, ts := range []time.Time{
+		time.Date(2021, 10, 28, 12, 0, 0, 0, time.UTC),
+		time.Date(2022, 3, 25, 12, 0, 0, 0, time.UTC),
+		time.Date(2022, 10, 27, 12, 0, 0, 0, time.UTC),
+		time.Date(2027, 10, 28, 12, 0, 0, 0, time.UTC),
+	} {
+		_, offset := ts.In(generated).Zone()
+		_, expect := ts.In(loc).Zone()
+		if !assert.Equal(t, expect, offset, `offset at %s should match`, ts) {
+			return
+		}
+	}
+}
+
+func TestEncodeWithTimezones(t *testing.T) {
+	c := ical.New()
+	e := ical.NewEvent()
+	e.AddProperty("uid", "berlin@example.com")
+	e.AddProperty("dtstart", "20200106T100000", ical.WithParameters(ical.Parameters{"TZID": []string{"Europe/Berlin"}}))
+	e.AddProperty("dtend", "20200106T110000", ical.WithParameters(ical.Parameters{"TZID": []string{"Europe/Berlin"}}))
+	c.AddEntry(e)
+
+	var buf strings.Builder
+	if !assert.NoError(t, ical.NewEncoder(&buf, ical.WithTimezones(true)).Encode(c), `Encode should succeed`) {
+		return
+	}
+	if !assert.Contains(t, buf.String(), "BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\n", `encoded calendar should contain a timezone`) {
+		return
+	}
+	if !assert.NotContains(t, c.String(), "BEGIN:VTIMEZONE", `calendar should not be modified`) {
+		return
+	}
+
+	parsed, err := ical.NewParser().Parse(strings.NewReader(buf.String()))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+
+	var count int
+	for e := range parsed.Entries() {
+		if _, ok := e.(*ical.Timezone); ok {
+			count++
+		}
+	}
+	if !assert.Equal(t, 1, count, `there should be exactly one timezone`) {
+		return
+	}
+
+	var again strings.Builder
+	if !assert.NoError(t, ical.NewEncoder(&again, ical.WithTimezones(true)).Encode(parsed), `Encode should succeed`) {
+		return
+	}
+	if !assert.Equal(t, buf.String(), again.String(), `defined timezones should not be added again`) {
+		return
+	}
+
+	e.AddProperty("recurrence-id", "20200106T100000", ical.WithParameters(ical.Parameters{"TZID": []string{"Nowhere Standard Time"}}))
+	var unknown strings.Builder
+	if !assert.NoError(t, ical.NewEncoder(&unknown, ical.WithTimezones(true)).Encode(c), `Encode should succeed with an unknown TZID`) {
+		return
+	}
+	if !assert.Contains(t, unknown.String(), "BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\n", `known timezones should still be added`) {
+		return
+	}
+	if !assert.Contains(t, unknown.String(), "END:VCALENDAR\r\n", `calendar should be written completely`) {
+		return
+	}
+}
+
+func TestEncodeWithTimezonesAlias(t *testing.T) {
+	c := ical.New()
+	e := ical.NewEvent()
+	e.AddProperty("uid", "alias@example.com")
+	e.AddProperty("dtstamp", "20200101T000000Z")
+	e.AddProperty("dtstart", "20200106T100000", ical.WithParameters(ical.Parameters{"TZID": []string{"W. Europe Standard Time"}}))
+	c.AddEntry(e)
+
+	var buf strings.Builder
+	if !assert.NoError(t, ical.NewEncoder(&buf, ical.WithTimezones(true)).Encode(c), `Encode should succeed`) {
+		return
+	}
+	if !assert.Contains(t, buf.String(), "BEGIN:VTIMEZONE\r\nTZID:W. Europe Standard Time\r\n", `timezone should use the referenced TZID`) {
+		return
+	}
+	if !assert.NotContains(t, buf.String(), "TZID:Europe/Berlin", `timezone should not use the resolved location name`) {
+		return
+	}
+
+	parsed, err := ical.NewParser().Parse(strings.NewReader(buf.String()))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+	if !assert.NoError(t, parsed.Validate(), `parsed calendar should be valid`) {
+		return
+	}
+}
@@ -0,0 +1,50 @@
+package ical_test
+
+import (
+	"testing"
+	"time"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimezoneAliases(t *testing.T) {
+	ical.RegisterTimezoneAlias("Head Office", "Asia/Tokyo")
+
+	for _, tc := range []struct {
+		TZID   string
+		Expect string
+	}{
+		{"W. Europe Standard Time", "Europe/Berlin"},
+		{"pacific standard time", "America/Los_Angeles"},
+		{"(UTC+01:00) Amsterdam, Berlin, Bern, Rome, Stockholm, Vienna", "Europe/Berlin"},
+		{"(UTC-05:00) Eastern Time (US & Canada)", "America/New_York"},
+		{"(UTC-08:00) Pacific Time (US & Canada)", "America/Los_Angeles"},
+		{"(UTC-06:00) Central Time (US & Canada)", "America/Chicago"},
+		{"(UTC+00:00) Dublin, Edinburgh, Lisbon, London", "Europe/London"},
+		{"(UTC+09:00) Osaka, Sapporo, Tokyo", "Asia/Tokyo"},
+		{"(UTC+10:00) Canberra, Melbourne, Sydney", "Australia/Sydney"},
+		{"(UTC) Coordinated Universal Time", "Etc/UTC"},
+		{"(GMT-05:00) Eastern Time (US & Canada), New York", "America/New_York"},
+		{"/mozilla.org/20050126_1/Europe/Berlin", "Europe/Berlin"},
+		{"/softwarestudio.org/Olson_20011030_5/America/New_York", "America/New_York"},
+		{"Head Office", "Asia/Tokyo"},
+	} {
+		tc := tc
+		t.Run(tc.TZID, func(t *testing.T) {
+			p := ical.NewProperty("dtstart", "20200106T100000", ical.Parameters{"TZID": []string{tc.TZID}})
+			v, err := p.Time(nil)
+			if !assert.NoError(t, err, `Time should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Expect, v.Location().String(), `location should match`) {
+				return
+			}
+		})
+	}
+
+	p := ical.NewProperty("dtstart", "20200106T100000", ical.Parameters{"TZID": []string{"Nowhere Standard Time"}})
+	if _, err := p.Time(time.UTC); !assert.Error(t, err, `unknown TZIDs should fail`) {
+		return
+	}
+}
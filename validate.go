@@ -0,0 +1,178 @@
+package ical
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError is a single violation of RFC 5545 found by Validate
+type ValidationError struct {
+	// Path locates the component, such as "VCALENDAR/VEVENT[1]". The
+	// index counts the siblings of the same type, starting at 0
+	Path string
+
+	// Property is the name of the offending property. It is empty for
+	// violations that concern the component as a whole
+	Property string
+
+	Message string
+}
+
+// ValidationErrors lists all violations found by Validate
+type ValidationErrors []*ValidationError
+
+func newValidationError(path, property, message string) *ValidationError {
+	return &ValidationError{
+		Path:     path,
+		Property: property,
+		Message:  message,
+	}
+}
+
+func (e *ValidationError) Error() string {
+	if e.Property == "" {
+		return e.Path + ": " + e.Message
+	}
+	return e.Path + ": " + e.Property + ": " + e.Message
+}
+
+func (l ValidationErrors) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return fmt.Sprintf("%d validation errors: %s", len(l), strings.Join(msgs, "; "))
+}
+
+// Validate reports the violations of RFC 5545 found in the component and
+// its sub-components as ValidationErrors
+func (v *Component) Validate() error {
+	return validateEntry(v)
+}
+
+func (v *Component) validateProperties(path string) ValidationErrors {
+	return nil
+}
+
+// validateEntry walks e and its sub-components. TZIDs are checked
+// against the VTIMEZONEs of the calendar when e is a calendar
+func validateEntry(e Entry) error {
+	var resolve locationResolver = loadLocation
+	var defined map[string]struct{}
+	if c, ok := e.(*Calendar); ok {
+		resolve = c.resolver()
+		defined = make(map[string]struct{})
+		for sub := range c.Entries() {
+			if tz, ok := sub.(*Timezone); ok {
+				if p, ok := tz.GetProperty("tzid"); ok {
+					defined[p.RawValue()] = struct{}{}
+				}
+			}
+		}
+	}
+
+	errs := validateComponent(e, e.Type(), defined, resolve)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// componentPath returns the path of a component named name, which is
+// preceded by index siblings of the same type, within the component at
+// parent. The path of a top-level component is its name
+func componentPath(parent, name string, index int) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "/" + name + "[" + strconv.Itoa(index) + "]"
+}
+
+func validateComponent(e Entry, path string, defined map[string]struct{}, resolve locationResolver) ValidationErrors {
+	var errs ValidationErrors
+	if v, ok := e.(interface {
+		validateProperties(string) ValidationErrors
+	}); ok {
+		errs = append(errs, v.validateProperties(path)...)
+	}
+
+	if defined != nil {
+		for p := range e.Properties() {
+			tzid, ok := p.params.Get("TZID")
+			if !ok {
+				continue
+			}
+			if _, ok := defined[tzid]; !ok {
+				errs = append(errs, newValidationError(path, p.Name(), `TZID `+strconv.Quote(tzid)+` has no matching VTIMEZONE`))
+			}
+		}
+	}
+
+	switch e.(type) {
+	case *Event, *Todo, *Journal:
+		errs = append(errs, validateTimes(e, path, resolve)...)
+	}
+
+	counts := make(map[string]int)
+	for sub := range e.Entries() {
+		typ := sub.Type()
+		subpath := componentPath(path, typ, counts[typ])
+		counts[typ]++
+		errs = append(errs, validateComponent(sub, subpath, defined, resolve)...)
+	}
+	return errs
+}
+
+// validateTimes checks that the DATE and DATE-TIME values of a component
+// are consistent with its DTSTART
+func validateTimes(e Entry, path string, resolve locationResolver) ValidationErrors {
+	var errs ValidationErrors
+
+	dtstart, startIsDate, ok, err := entryTime(e, "dtstart", time.UTC, resolve)
+	if err != nil {
+		return append(errs, newValidationError(path, "dtstart", err.Error()))
+	}
+	if !ok {
+		return nil
+	}
+
+	for _, name := range []string{"dtend", "due"} {
+		t, isDate, ok, err := entryTime(e, name, time.UTC, resolve)
+		switch {
+		case err != nil:
+			errs = append(errs, newValidationError(path, name, err.Error()))
+		case !ok:
+		case isDate != startIsDate:
+			errs = append(errs, newValidationError(path, name, `value type must match that of dtstart`))
+		case t.Before(dtstart):
+			errs = append(errs, newValidationError(path, name, `must not be before dtstart`))
+		}
+	}
+
+	if _, isDate, ok, err := entryTime(e, "recurrence-id", time.UTC, resolve); err == nil && ok && isDate != startIsDate {
+		errs = append(errs, newValidationError(path, "recurrence-id", `value type must match that of dtstart`))
+	}
+
+	for _, p := range entryProperties(e, "exdate") {
+		if p.IsDate() != startIsDate {
+			errs = append(errs, newValidationError(path, "exdate", `value type must match that of dtstart`))
+		}
+	}
+
+	for _, p := range entryProperties(e, "rrule") {
+		r, err := p.Recur()
+		if err != nil {
+			errs = append(errs, newValidationError(path, "rrule", err.Error()))
+			continue
+		}
+		if r.Until.IsZero() {
+			continue
+		}
+		if r.untilDate != startIsDate {
+			errs = append(errs, newValidationError(path, "rrule", `UNTIL must have the value type of dtstart`))
+		}
+	}
+	return errs
+}
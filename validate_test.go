@@ -0,0 +1,116 @@
+package ical_test
+
+import (
+	"strings"
+	"testing"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`BEGIN:VEVENT`,
+		`DTSTART;TZID=Europe/Berlin:20200106T100000`,
+		`DTEND;TZID=Europe/Berlin:20200106T090000`,
+		`DURATION:PT1H`,
+		`BEGIN:VALARM`,
+		`ACTION:DISPLAY`,
+		`REPEAT:2`,
+		`END:VALARM`,
+		`END:VEVENT`,
+		`BEGIN:VEVENT`,
+		`DTSTAMP:20200101T000000Z`,
+		`UID:second@example.com`,
+		`DTSTART;VALUE=DATE:20200106`,
+		`DTEND:20200107T000000Z`,
+		`RRULE:FREQ=DAILY;UNTIL=20200110T000000Z`,
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+
+	err = c.Validate()
+	errs, ok := err.(ical.ValidationErrors)
+	if !assert.True(t, ok, `Validate should return ValidationErrors`) {
+		return
+	}
+
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Path+" "+e.Property)
+	}
+	expect := []string{
+		"VCALENDAR prodid",
+		"VCALENDAR/VEVENT[0] dtstamp",
+		"VCALENDAR/VEVENT[0] uid",
+		"VCALENDAR/VEVENT[0] duration",
+		"VCALENDAR/VEVENT[0] dtend",
+		"VCALENDAR/VEVENT[0] dtstart",
+		"VCALENDAR/VEVENT[0] dtend",
+		"VCALENDAR/VEVENT[0]/VALARM[0] trigger",
+		"VCALENDAR/VEVENT[0]/VALARM[0] repeat",
+		"VCALENDAR/VEVENT[1] dtend",
+		"VCALENDAR/VEVENT[1] rrule",
+	}
+	if !assert.Equal(t, expect, got, `violations should match`) {
+		return
+	}
+	if !assert.Contains(t, err.Error(), `VCALENDAR/VEVENT[0]: dtend: must not be before dtstart`, `error message should describe the violation`) {
+		return
+	}
+}
+
+func TestValidateParsedCalendar(t *testing.T) {
+	src := "BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+	errs, ok := c.Validate().(ical.ValidationErrors)
+	if !assert.True(t, ok, `Validate should return ValidationErrors`) {
+		return
+	}
+	var got []string
+	for _, e := range errs {
+		got = append(got, e.Path+" "+e.Property)
+	}
+	if !assert.Equal(t, []string{"VCALENDAR prodid", "VCALENDAR version"}, got, `missing properties should be reported`) {
+		return
+	}
+	if !assert.Equal(t, src, c.String(), `no properties should be added`) {
+		return
+	}
+
+	decoded, err := ical.NewJSONDecoder(strings.NewReader(`["vcalendar",[],[]]`)).Decode()
+	if !assert.NoError(t, err, `Decode should succeed`) {
+		return
+	}
+	if !assert.Error(t, decoded.Validate(), `decoded calendar should be invalid`) {
+		return
+	}
+}
+
+func TestValidateValid(t *testing.T) {
+	c := ical.New()
+	e := ical.NewEvent()
+	e.AddProperty("uid", "valid@example.com")
+	e.AddProperty("dtstamp", "20200101T000000Z")
+	e.AddProperty("dtstart", "20200106T100000Z")
+	e.AddProperty("dtend", "20200106T110000Z")
+	c.AddEntry(e)
+
+	if !assert.NoError(t, c.Validate(), `Validate should succeed`) {
+		return
+	}
+	if !assert.NoError(t, e.Validate(), `Validate should succeed`) {
+		return
+	}
+}
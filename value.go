@@ -0,0 +1,687 @@
+package ical
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	dateFormat        = "20060102"
+	dateTimeFormat    = "20060102T150405"
+	utcDateTimeFormat = "20060102T150405Z"
+)
+
+// locationResolver maps a TZID parameter value to a location
+type locationResolver func(string) (*time.Location, error)
+
+// locationTZID returns the TZID that times in loc are written with.
+// Only locations of the IANA database have one. Times in other
+// locations, including time.Local, are written in UTC instead
+func locationTZID(loc *time.Location) (string, bool) {
+	if loc == time.UTC || loc == time.Local {
+		return "", false
+	}
+	switch name := loc.String(); name {
+	case "", "UTC", "Local":
+		return "", false
+	default:
+		if _, err := time.LoadLocation(name); err != nil {
+			return "", false
+		}
+		return name, true
+	}
+}
+
+// loadLocation resolves tzid using the registered aliases, the IANA
+// database, and finally the builtin aliases
+func loadLocation(tzid string) (*time.Location, error) {
+	name := tzid
+	if alias, ok := registeredTimezoneAlias(tzid); ok {
+		name = alias
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err == nil {
+		return loc, nil
+	}
+
+	if alias, ok := guessTimezone(tzid); ok {
+		if loc, aliasErr := time.LoadLocation(alias); aliasErr == nil {
+			return loc, nil
+		}
+	}
+	return nil, errors.Wrapf(err, `failed to load location for TZID %s`, tzid)
+}
+
+// parseDateTime parses a DATE or DATE-TIME value. Values in UTC form
+// are returned in UTC, everything else is interpreted in loc. The
+// boolean return value reports if the value was a DATE
+func parseDateTime(s string, loc *time.Location) (time.Time, bool, error) {
+	switch len(s) {
+	case len(dateFormat):
+		t, err := time.ParseInLocation(dateFormat, s, loc)
+		if err != nil {
+			return time.Time{}, false, errors.Wrapf(err, `invalid date value '%s'`, s)
+		}
+		return t, true, nil
+	case len(utcDateTimeFormat):
+		t, err := time.Parse(utcDateTimeFormat, s)
+		if err != nil {
+			return time.Time{}, false, errors.Wrapf(err, `invalid date-time value '%s'`, s)
+		}
+		return t, false, nil
+	case len(dateTimeFormat):
+		t, err := time.ParseInLocation(dateTimeFormat, s, loc)
+		if err != nil {
+			return time.Time{}, false, errors.Wrapf(err, `invalid date-time value '%s'`, s)
+		}
+		return t, false, nil
+	}
+	return time.Time{}, false, errors.Errorf(`invalid date or date-time value '%s'`, s)
+}
+
+// propertyLocation returns the location that the values of p should be
+// interpreted in: the location named by its TZID parameter if present,
+// floating otherwise
+func propertyLocation(p *Property, floating *time.Location, resolve locationResolver) (*time.Location, error) {
+	tzid, ok := p.params.Get("TZID")
+	if !ok {
+		return floating, nil
+	}
+	if resolve == nil {
+		resolve = loadLocation
+	}
+	return resolve(tzid)
+}
+
+// nominalDuration is a DURATION value. Days and weeks are nominal, and
+// are added to the wall clock, so that a day lasts 23 or 25 hours
+// across a daylight saving time transition. Hours, minutes and seconds
+// are exact
+type nominalDuration struct {
+	days  int
+	exact time.Duration
+}
+
+// addTo returns t shifted by the duration
+func (d nominalDuration) addTo(t time.Time) time.Time {
+	return t.AddDate(0, 0, d.days).Add(d.exact)
+}
+
+// approx returns the duration, taking a day to be 24 hours
+func (d nominalDuration) approx() time.Duration {
+	return time.Duration(d.days)*24*time.Hour + d.exact
+}
+
+// parseDuration parses a DURATION value such as "P1W", "-PT15M" or
+// "P1DT2H30M"
+func parseDuration(s string) (nominalDuration, error) {
+	orig := s
+	sign := 1
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign = -1
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	if !strings.HasPrefix(s, "P") {
+		return nominalDuration{}, errors.Errorf(`invalid duration value '%s'`, orig)
+	}
+	s = s[1:]
+
+	var d nominalDuration
+	var intime, seen bool
+	for len(s) > 0 {
+		if s[0] == 'T' {
+			intime = true
+			s = s[1:]
+			continue
+		}
+
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return nominalDuration{}, errors.Errorf(`invalid duration value '%s'`, orig)
+		}
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return nominalDuration{}, errors.Wrapf(err, `invalid duration value '%s'`, orig)
+		}
+		n *= sign
+
+		switch c := s[i]; {
+		case c == 'W' && !intime:
+			d.days += 7 * n
+		case c == 'D' && !intime:
+			d.days += n
+		case c == 'H' && intime:
+			d.exact += time.Duration(n) * time.Hour
+		case c == 'M' && intime:
+			d.exact += time.Duration(n) * time.Minute
+		case c == 'S' && intime:
+			d.exact += time.Duration(n) * time.Second
+		default:
+			return nominalDuration{}, errors.Errorf(`invalid duration value '%s'`, orig)
+		}
+		seen = true
+		s = s[i+1:]
+	}
+
+	if !seen {
+		return nominalDuration{}, errors.Errorf(`invalid duration value '%s'`, orig)
+	}
+	return d, nil
+}
+
+// parsePeriod parses a PERIOD value, either in the explicit
+// "start/end" form or the "start/duration" form
+func parsePeriod(s string, loc *time.Location) (time.Time, time.Time, error) {
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return time.Time{}, time.Time{}, errors.Errorf(`invalid period value '%s'`, s)
+	}
+
+	start, _, err := parseDateTime(s[:i], loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrap(err, `invalid period start`)
+	}
+
+	rest := s[i+1:]
+	if strings.HasPrefix(rest, "P") || strings.HasPrefix(rest, "+") || strings.HasPrefix(rest, "-") {
+		d, err := parseDuration(rest)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.Wrap(err, `invalid period duration`)
+		}
+		return start, d.addTo(start), nil
+	}
+
+	end, _, err := parseDateTime(rest, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.Wrap(err, `invalid period end`)
+	}
+	return start, end, nil
+}
+
+type ValueType string
+
+const (
+	ValueBinary     ValueType = "BINARY"
+	ValueBoolean    ValueType = "BOOLEAN"
+	ValueCalAddress ValueType = "CAL-ADDRESS"
+	ValueDate       ValueType = "DATE"
+	ValueDateTime   ValueType = "DATE-TIME"
+	ValueDuration   ValueType = "DURATION"
+	ValueFloat      ValueType = "FLOAT"
+	ValueInteger    ValueType = "INTEGER"
+	ValuePeriod     ValueType = "PERIOD"
+	ValueRecur      ValueType = "RECUR"
+	ValueText       ValueType = "TEXT"
+	ValueTime       ValueType = "TIME"
+	ValueURI        ValueType = "URI"
+	ValueUTCOffset  ValueType = "UTC-OFFSET"
+)
+
+// defaultValueTypes lists the default value type of each property
+// defined in RFC 5545 (and RFC 7986) whose value is not TEXT
+var defaultValueTypes = map[string]ValueType{
+	"attach":           ValueURI,
+	"geo":              ValueFloat,
+	"percent-complete": ValueInteger,
+	"priority":         ValueInteger,
+	"completed":        ValueDateTime,
+	"dtend":            ValueDateTime,
+	"due":              ValueDateTime,
+	"dtstart":          ValueDateTime,
+	"duration":         ValueDuration,
+	"freebusy":         ValuePeriod,
+	"tzoffsetfrom":     ValueUTCOffset,
+	"tzoffsetto":       ValueUTCOffset,
+	"tzurl":            ValueURI,
+	"attendee":         ValueCalAddress,
+	"organizer":        ValueCalAddress,
+	"recurrence-id":    ValueDateTime,
+	"url":              ValueURI,
+	"exdate":           ValueDateTime,
+	"rdate":            ValueDateTime,
+	"rrule":            ValueRecur,
+	"exrule":           ValueRecur,
+	"trigger":          ValueDuration,
+	"created":          ValueDateTime,
+	"dtstamp":          ValueDateTime,
+	"last-modified":    ValueDateTime,
+	"sequence":         ValueInteger,
+	"repeat":           ValueInteger,
+	"refresh-interval": ValueDuration,
+	"source":           ValueURI,
+	"image":            ValueURI,
+	"conference":       ValueURI,
+}
+
+// ValueType returns the value type of the property: the one specified
+// by its VALUE parameter, or the default type of the property
+func (p Property) ValueType() ValueType {
+	if v, ok := p.params.Get("VALUE"); ok {
+		return ValueType(strings.ToUpper(v))
+	}
+	if vt, ok := defaultValueTypes[p.name]; ok {
+		return vt
+	}
+	return ValueText
+}
+
+func (p Property) expectValueType(types ...ValueType) error {
+	vt := p.ValueType()
+	for _, t := range types {
+		if vt == t {
+			return nil
+		}
+	}
+	return errors.Errorf(`property %s has value type %s, not %s`, p.name, vt, types[0])
+}
+
+// Time returns the DATE or DATE-TIME value of the property. Values with
+// a TZID parameter are returned in that location, UTC values in UTC and
+// floating values and dates in loc (time.Local if nil)
+func (p Property) Time(loc *time.Location) (time.Time, error) {
+	l, err := p.Times(loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return l[0], nil
+}
+
+// Times returns the DATE or DATE-TIME values of a multi-valued
+// property such as EXDATE or RDATE. See Time for how locations are handled
+func (p Property) Times(loc *time.Location) ([]time.Time, error) {
+	if err := p.expectValueType(ValueDateTime, ValueDate); err != nil {
+		return nil, err
+	}
+
+	if loc == nil {
+		loc = time.Local
+	}
+	loc, err := propertyLocation(&p, loc, loadLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	var l []time.Time
+	for _, s := range strings.Split(p.value, ",") {
+		t, _, err := parseDateTime(s, loc)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, t)
+	}
+	return l, nil
+}
+
+// IsDate reports if the value of the property is a DATE rather than
+// a DATE-TIME
+func (p Property) IsDate() bool {
+	return p.ValueType() == ValueDate || (p.ValueType() == ValueDateTime && len(p.value) == len(dateFormat))
+}
+
+// IsFloating reports if the DATE-TIME value of the property is neither
+// in UTC nor bound to a TZID
+func (p Property) IsFloating() bool {
+	if _, ok := p.params.Get("TZID"); ok {
+		return false
+	}
+	return len(p.value) == len(dateTimeFormat)
+}
+
+// Duration returns the DURATION value of the property. Days and weeks
+// are taken to be 24 and 168 hours, although they last longer or
+// shorter across a daylight saving time transition
+func (p Property) Duration() (time.Duration, error) {
+	if err := p.expectValueType(ValueDuration); err != nil {
+		return 0, err
+	}
+	d, err := parseDuration(p.value)
+	if err != nil {
+		return 0, err
+	}
+	return d.approx(), nil
+}
+
+// Period is the value of a PERIOD property
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (v Period) Duration() time.Duration {
+	return v.End.Sub(v.Start)
+}
+
+// String formats the period in the location of its start, or in UTC if
+// that is not a location of the IANA database
+func (v Period) String() string {
+	if _, ok := locationTZID(v.Start.Location()); !ok {
+		return v.Start.UTC().Format(utcDateTimeFormat) + "/" + v.End.UTC().Format(utcDateTimeFormat)
+	}
+	return v.Start.Format(dateTimeFormat) + "/" + v.End.In(v.Start.Location()).Format(dateTimeFormat)
+}
+
+// Period returns the first PERIOD value of the property
+func (p Property) Period(loc *time.Location) (Period, error) {
+	l, err := p.Periods(loc)
+	if err != nil {
+		return Period{}, err
+	}
+	return l[0], nil
+}
+
+// Periods returns the PERIOD values of a multi-valued property such as
+// FREEBUSY or RDATE
+func (p Property) Periods(loc *time.Location) ([]Period, error) {
+	if err := p.expectValueType(ValuePeriod); err != nil {
+		return nil, err
+	}
+
+	if loc == nil {
+		loc = time.Local
+	}
+	loc, err := propertyLocation(&p, loc, loadLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	var l []Period
+	for _, s := range strings.Split(p.value, ",") {
+		start, end, err := parsePeriod(s, loc)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, Period{Start: start, End: end})
+	}
+	return l, nil
+}
+
+// UTCOffset returns the UTC-OFFSET value of the property
+func (p Property) UTCOffset() (time.Duration, error) {
+	if err := p.expectValueType(ValueUTCOffset); err != nil {
+		return 0, err
+	}
+	return parseUTCOffset(p.value)
+}
+
+func (p Property) Int() (int, error) {
+	if err := p.expectValueType(ValueInteger); err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(p.value)
+	if err != nil {
+		return 0, errors.Wrapf(err, `invalid integer value '%s'`, p.value)
+	}
+	return n, nil
+}
+
+// Float returns the FLOAT value of the property. For multi-valued
+// properties such as GEO, use Floats
+func (p Property) Float() (float64, error) {
+	l, err := p.Floats()
+	if err != nil {
+		return 0, err
+	}
+	return l[0], nil
+}
+
+// Floats returns the semicolon separated FLOAT values of the property
+func (p Property) Floats() ([]float64, error) {
+	if err := p.expectValueType(ValueFloat); err != nil {
+		return nil, err
+	}
+
+	var l []float64
+	for _, s := range strings.Split(p.value, ";") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, `invalid float value '%s'`, s)
+		}
+		l = append(l, f)
+	}
+	return l, nil
+}
+
+func (p Property) Bool() (bool, error) {
+	if err := p.expectValueType(ValueBoolean); err != nil {
+		return false, err
+	}
+	switch strings.ToUpper(p.value) {
+	case "TRUE":
+		return true, nil
+	case "FALSE":
+		return false, nil
+	}
+	return false, errors.Errorf(`invalid boolean value '%s'`, p.value)
+}
+
+// CalAddress returns the CAL-ADDRESS value of the property, typically
+// a mailto: URI
+func (p Property) CalAddress() (*url.URL, error) {
+	if err := p.expectValueType(ValueCalAddress); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(p.value)
+	if err != nil {
+		return nil, errors.Wrapf(err, `invalid cal-address value '%s'`, p.value)
+	}
+	return u, nil
+}
+
+func (p Property) URI() (*url.URL, error) {
+	if err := p.expectValueType(ValueURI); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(p.value)
+	if err != nil {
+		return nil, errors.Wrapf(err, `invalid uri value '%s'`, p.value)
+	}
+	return u, nil
+}
+
+// Binary returns the decoded BINARY value of the property
+func (p Property) Binary() ([]byte, error) {
+	if err := p.expectValueType(ValueBinary); err != nil {
+		return nil, err
+	}
+	if p.binary != nil {
+		return p.binary.bytes()
+	}
+	b, err := base64.StdEncoding.DecodeString(p.value)
+	if err != nil {
+		return nil, errors.Wrap(err, `invalid base64 value`)
+	}
+	return b, nil
+}
+
+// Recur returns the RECUR value of the property. Values read from a
+// vCalendar 1.0 calendar are parsed with ParseVCal10Recur
+func (p Property) Recur() (*Recur, error) {
+	if err := p.expectValueType(ValueRecur); err != nil {
+		return nil, err
+	}
+	if p.vcal10 {
+		return ParseVCal10Recur(p.value)
+	}
+	return ParseRecur(p.value)
+}
+
+func parseUTCOffset(s string) (time.Duration, error) {
+	if (len(s) != 5 && len(s) != 7) || (s[0] != '+' && s[0] != '-') {
+		return 0, errors.Errorf(`invalid utc-offset value '%s'`, s)
+	}
+
+	var d time.Duration
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	for i := 1; i < len(s); i += 2 {
+		n, err := strconv.Atoi(s[i : i+2])
+		if err != nil {
+			return 0, errors.Wrapf(err, `invalid utc-offset value '%s'`, s)
+		}
+		d += time.Duration(n) * units[i/2]
+	}
+
+	if s[0] == '-' {
+		d = -d
+	}
+	return d, nil
+}
+
+func formatUTCOffset(d time.Duration) string {
+	sign := byte('+')
+	if d < 0 {
+		sign = '-'
+		d = -d
+	}
+
+	secs := int(d / time.Second)
+	s := string(sign) + fmt.Sprintf("%02d%02d", secs/3600, secs/60%60)
+	if secs%60 != 0 {
+		s += fmt.Sprintf("%02d", secs%60)
+	}
+	return s
+}
+
+// formatDuration formats d in hours, minutes and seconds, as days and
+// weeks are nominal units that do not always last 24 and 168 hours
+func formatDuration(d time.Duration) string {
+	var buf strings.Builder
+	if d < 0 {
+		buf.WriteByte('-')
+		d = -d
+	}
+	buf.WriteString("PT")
+
+	h, m, s := d/time.Hour, d/time.Minute%60, d/time.Second%60
+	if h > 0 {
+		buf.WriteString(strconv.FormatInt(int64(h), 10))
+		buf.WriteByte('H')
+	}
+	if m > 0 {
+		buf.WriteString(strconv.FormatInt(int64(m), 10))
+		buf.WriteByte('M')
+	}
+	if s > 0 || (h == 0 && m == 0) {
+		buf.WriteString(strconv.FormatInt(int64(s), 10))
+		buf.WriteByte('S')
+	}
+	return buf.String()
+}
+
+// newTypedProperty creates a property, adding a VALUE parameter if vt
+// is not the default value type of the property
+func newTypedProperty(name, value string, vt ValueType, params Parameters) *Property {
+	name = strings.ToLower(name)
+	if params == nil {
+		params = Parameters{}
+	}
+
+	def, ok := defaultValueTypes[name]
+	if !ok {
+		def = ValueText
+	}
+	if def != vt {
+		params.Set("VALUE", string(vt))
+	}
+	return NewProperty(name, value, params)
+}
+
+// NewDateTimeProperty creates a DATE-TIME property. Times in a location
+// of the IANA database are qualified with its name as the TZID
+// parameter, all other times are written in UTC form
+func NewDateTimeProperty(name string, t time.Time) *Property {
+	if tzid, ok := locationTZID(t.Location()); ok {
+		return newTypedProperty(name, t.Format(dateTimeFormat), ValueDateTime, Parameters{"TZID": []string{tzid}})
+	}
+	return newTypedProperty(name, t.UTC().Format(utcDateTimeFormat), ValueDateTime, nil)
+}
+
+// NewFloatingDateTimeProperty creates a DATE-TIME property holding the
+// wall clock of t, without any reference to its location
+func NewFloatingDateTimeProperty(name string, t time.Time) *Property {
+	return newTypedProperty(name, t.Format(dateTimeFormat), ValueDateTime, nil)
+}
+
+func NewDateProperty(name string, t time.Time) *Property {
+	return newTypedProperty(name, t.Format(dateFormat), ValueDate, nil)
+}
+
+func NewDurationProperty(name string, d time.Duration) *Property {
+	return newTypedProperty(name, formatDuration(d), ValueDuration, nil)
+}
+
+func NewPeriodProperty(name string, periods ...Period) *Property {
+	l := make([]string, len(periods))
+	for i, v := range periods {
+		l[i] = v.String()
+	}
+
+	var params Parameters
+	if len(periods) > 0 {
+		if tzid, ok := locationTZID(periods[0].Start.Location()); ok {
+			params = Parameters{"TZID": []string{tzid}}
+		}
+	}
+	return newTypedProperty(name, strings.Join(l, ","), ValuePeriod, params)
+}
+
+func NewUTCOffsetProperty(name string, d time.Duration) *Property {
+	return newTypedProperty(name, formatUTCOffset(d), ValueUTCOffset, nil)
+}
+
+func NewIntegerProperty(name string, n int) *Property {
+	return newTypedProperty(name, strconv.Itoa(n), ValueInteger, nil)
+}
+
+func NewFloatProperty(name string, f float64) *Property {
+	return newTypedProperty(name, strconv.FormatFloat(f, 'f', -1, 64), ValueFloat, nil)
+}
+
+func NewBooleanProperty(name string, b bool) *Property {
+	s := "FALSE"
+	if b {
+		s = "TRUE"
+	}
+	return newTypedProperty(name, s, ValueBoolean, nil)
+}
+
+func NewCalAddressProperty(name string, u *url.URL) *Property {
+	return newTypedProperty(name, u.String(), ValueCalAddress, nil)
+}
+
+func NewURIProperty(name string, u *url.URL) *Property {
+	return newTypedProperty(name, u.String(), ValueURI, nil)
+}
+
+// NewBinaryProperty creates a BINARY property holding the base64
+// encoded form of b
+func NewBinaryProperty(name string, b []byte) *Property {
+	return newTypedProperty(name, base64.StdEncoding.EncodeToString(b), ValueBinary, Parameters{"ENCODING": []string{"BASE64"}})
+}
+
+func NewRecurProperty(name string, r *Recur) *Property {
+	return newTypedProperty(name, r.String(), ValueRecur, nil)
+}
+
+// AddProperty adds a property created by one of the typed constructors
+// such as NewDateTimeProperty to the entry
+func AddProperty(e Entry, p *Property) error {
+	inlined, err := p.inline()
+	if err != nil {
+		return errors.Wrapf(err, `failed to add property %s`, p.name)
+	}
+	return e.AddProperty(inlined.name, inlined.value, WithParameters(inlined.params))
+}
@@ -0,0 +1,206 @@
+package ical_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPropertyValues(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if !assert.NoError(t, err, `time.LoadLocation should succeed`) {
+		return
+	}
+
+	t.Run("date-time", func(t *testing.T) {
+		p := ical.NewProperty("dtstart", "20200102T030405Z", nil)
+		v, err := p.Time(nil)
+		if !assert.NoError(t, err, `Time should succeed`) {
+			return
+		}
+		if !assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), v) {
+			return
+		}
+
+		p = ical.NewProperty("dtstart", "20200102T030405", ical.Parameters{"TZID": []string{"Asia/Tokyo"}})
+		v, err = p.Time(nil)
+		if !assert.NoError(t, err, `Time should succeed`) {
+			return
+		}
+		if !assert.Equal(t, tokyo, v.Location(), `location should be taken from TZID`) {
+			return
+		}
+
+		p = ical.NewProperty("dtstart", "20200102T030405", nil)
+		v, err = p.Time(tokyo)
+		if !assert.NoError(t, err, `Time should succeed`) {
+			return
+		}
+		if !assert.True(t, p.IsFloating(), `value should be floating`) {
+			return
+		}
+		if !assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, tokyo), v, `floating value should be in given location`) {
+			return
+		}
+
+		p = ical.NewProperty("dtstart", "20200102", ical.Parameters{"VALUE": []string{"DATE"}})
+		if !assert.True(t, p.IsDate(), `value should be a date`) {
+			return
+		}
+
+		p = ical.NewProperty("exdate", "20200102T030405Z,20200103T030405Z", nil)
+		l, err := p.Times(nil)
+		if !assert.NoError(t, err, `Times should succeed`) {
+			return
+		}
+		if !assert.Len(t, l, 2, `there should be 2 values`) {
+			return
+		}
+
+		_, err = ical.NewProperty("summary", "20200102T030405Z", nil).Time(nil)
+		if !assert.Error(t, err, `Time on a TEXT property should fail`) {
+			return
+		}
+	})
+
+	t.Run("duration", func(t *testing.T) {
+		for _, tc := range []struct {
+			Value     string
+			Duration  time.Duration
+			Formatted string
+		}{
+			{"P1W", 7 * 24 * time.Hour, "PT168H"},
+			{"-PT15M", -15 * time.Minute, "-PT15M"},
+			{"P1DT2H3M4S", 26*time.Hour + 3*time.Minute + 4*time.Second, "PT26H3M4S"},
+			{"PT0S", 0, "PT0S"},
+		} {
+			p := ical.NewProperty("duration", tc.Value, nil)
+			v, err := p.Duration()
+			if !assert.NoError(t, err, `Duration should succeed for %s`, tc.Value) {
+				return
+			}
+			if !assert.Equal(t, tc.Duration, v, `duration should match for %s`, tc.Value) {
+				return
+			}
+			if !assert.Equal(t, tc.Formatted, ical.NewDurationProperty("duration", tc.Duration).RawValue(), `formatted duration should match`) {
+				return
+			}
+		}
+
+		_, err := ical.NewProperty("duration", "P1H", nil).Duration()
+		if !assert.Error(t, err, `Duration should fail for invalid value`) {
+			return
+		}
+	})
+
+	t.Run("period", func(t *testing.T) {
+		p := ical.NewProperty("freebusy", "19970308T160000Z/PT8H30M,19970308T230000Z/19970309T000000Z", nil)
+		l, err := p.Periods(nil)
+		if !assert.NoError(t, err, `Periods should succeed`) {
+			return
+		}
+		if !assert.Len(t, l, 2, `there should be 2 periods`) {
+			return
+		}
+		if !assert.Equal(t, 8*time.Hour+30*time.Minute, l[0].Duration(), `duration should match`) {
+			return
+		}
+		if !assert.Equal(t, "19970308T160000Z/19970309T003000Z,19970308T230000Z/19970309T000000Z", ical.NewPeriodProperty("freebusy", l...).RawValue()) {
+			return
+		}
+	})
+
+	t.Run("utc-offset", func(t *testing.T) {
+		p := ical.NewProperty("tzoffsetfrom", "-0530", nil)
+		v, err := p.UTCOffset()
+		if !assert.NoError(t, err, `UTCOffset should succeed`) {
+			return
+		}
+		if !assert.Equal(t, -(5*time.Hour + 30*time.Minute), v) {
+			return
+		}
+		if !assert.Equal(t, "+090015", ical.NewUTCOffsetProperty("tzoffsetto", 9*time.Hour+15*time.Second).RawValue()) {
+			return
+		}
+	})
+
+	t.Run("scalars", func(t *testing.T) {
+		n, err := ical.NewProperty("priority", "3", nil).Int()
+		if !assert.NoError(t, err, `Int should succeed`) || !assert.Equal(t, 3, n) {
+			return
+		}
+
+		geo, err := ical.NewProperty("geo", "37.386013;-122.082932", nil).Floats()
+		if !assert.NoError(t, err, `Floats should succeed`) || !assert.Equal(t, []float64{37.386013, -122.082932}, geo) {
+			return
+		}
+
+		b, err := ical.NewBooleanProperty("x-flag", true).Bool()
+		if !assert.NoError(t, err, `Bool should succeed`) || !assert.True(t, b) {
+			return
+		}
+
+		u, err := ical.NewProperty("attendee", "mailto:jdoe@example.com", nil).CalAddress()
+		if !assert.NoError(t, err, `CalAddress should succeed`) || !assert.Equal(t, "jdoe@example.com", u.Opaque) {
+			return
+		}
+
+		data, err := ical.NewBinaryProperty("attach", []byte("hello")).Binary()
+		if !assert.NoError(t, err, `Binary should succeed`) || !assert.Equal(t, []byte("hello"), data) {
+			return
+		}
+	})
+
+	t.Run("constructors", func(t *testing.T) {
+		e := ical.NewEvent()
+		props := []*ical.Property{
+			ical.NewDateTimeProperty("dtstart", time.Date(2020, 1, 2, 9, 0, 0, 0, tokyo)),
+			ical.NewDateTimeProperty("dtstamp", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)),
+			ical.NewDateProperty("dtend", time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)),
+			ical.NewIntegerProperty("priority", 1),
+			ical.NewURIProperty("url", &url.URL{Scheme: "https", Host: "example.com", Path: "/calendar"}),
+		}
+		for _, p := range props {
+			if !assert.NoError(t, ical.AddProperty(e, p), `AddProperty should succeed`) {
+				return
+			}
+		}
+
+		expect := "BEGIN:VEVENT\r\n" +
+			"DTEND;VALUE=DATE:20200103\r\n" +
+			"DTSTAMP:20200101T000000Z\r\n" +
+			"DTSTART;TZID=Asia/Tokyo:20200102T090000\r\n" +
+			"PRIORITY:1\r\n" +
+			"URL:https://example.com/calendar\r\n" +
+			"END:VEVENT\r\n"
+		if !assert.Equal(t, expect, e.String()) {
+			return
+		}
+	})
+
+	t.Run("unnamed locations", func(t *testing.T) {
+		for _, loc := range []*time.Location{time.Local, time.FixedZone("JST", 9*60*60)} {
+			v := time.Date(2020, 1, 2, 9, 0, 0, 0, loc)
+			expect := v.UTC().Format("20060102T150405Z")
+
+			p := ical.NewDateTimeProperty("dtstart", v)
+			if _, ok := p.Parameters().Get("TZID"); !assert.False(t, ok, `TZID should not be set for %s`, loc) {
+				return
+			}
+			if !assert.Equal(t, expect, p.RawValue(), `time should be written in UTC for %s`, loc) {
+				return
+			}
+
+			p = ical.NewPeriodProperty("freebusy", ical.Period{Start: v, End: v.Add(time.Hour)})
+			if _, ok := p.Parameters().Get("TZID"); !assert.False(t, ok, `TZID should not be set for %s`, loc) {
+				return
+			}
+			if !assert.Equal(t, expect+"/"+v.Add(time.Hour).UTC().Format("20060102T150405Z"), p.RawValue(), `period should be written in UTC for %s`, loc) {
+				return
+			}
+		}
+	})
+}
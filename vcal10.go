@@ -0,0 +1,596 @@
+package ical
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// vCalendar 1.0 recurrence rule frequencies, longest prefix first
+var vcal10Frequencies = []struct {
+	prefix string
+	freq   Frequency
+}{
+	{"MP", FreqMonthly},
+	{"MD", FreqMonthly},
+	{"YM", FreqYearly},
+	{"YD", FreqYearly},
+	{"D", FreqDaily},
+	{"W", FreqWeekly},
+}
+
+// ParseVCal10Recur parses a vCalendar 1.0 recurrence rule such as
+// "W1 MO TU #10" or "MP1 1+ SU 1- SU 19991231T000000Z". As defined by
+// vCalendar 1.0, a rule without a duration or end date repeats twice,
+// and "#0" repeats forever
+func ParseVCal10Recur(s string) (*Recur, error) {
+	fields := strings.Fields(strings.ToUpper(s))
+	if len(fields) == 0 {
+		return nil, errors.New(`empty recur rule`)
+	}
+
+	var r Recur
+	var prefix string
+	for _, f := range vcal10Frequencies {
+		if strings.HasPrefix(fields[0], f.prefix) {
+			prefix = f.prefix
+			r.Freq = f.freq
+			break
+		}
+	}
+	if prefix == "" {
+		return nil, errors.Errorf(`invalid frequency '%s'`, fields[0])
+	}
+	interval, err := strconv.Atoi(fields[0][len(prefix):])
+	if err != nil || interval < 1 {
+		return nil, errors.Errorf(`invalid interval '%s'`, fields[0])
+	}
+	if interval > 1 {
+		r.Interval = interval
+	}
+
+	var ended bool
+	var ordinals []int
+	var weekdays bool // weekdays were given for the pending ordinals
+	for _, tok := range fields[1:] {
+		// '$' marks instances modified by the application, and has no
+		// bearing on the rule itself
+		tok = strings.TrimSuffix(tok, "$")
+		if ended {
+			return nil, errors.Errorf(`unexpected '%s' after end of rule`, tok)
+		}
+
+		switch {
+		case strings.HasPrefix(tok, "#"):
+			n, err := strconv.Atoi(tok[1:])
+			if err != nil || n < 0 {
+				return nil, errors.Errorf(`invalid duration '%s'`, tok)
+			}
+			r.Count = n
+			ended = true
+			continue
+		case len(tok) >= len(dateFormat):
+			if err := r.parseUntil(tok); err != nil {
+				return nil, errors.Wrap(err, `invalid end date`)
+			}
+			ended = true
+			continue
+		}
+
+		if wd, err := parseWeekday(tok); err == nil {
+			switch prefix {
+			case "W":
+				r.ByDay = append(r.ByDay, WeekdayNum{Weekday: wd})
+			case "MP":
+				if len(ordinals) == 0 {
+					return nil, errors.Errorf(`weekday '%s' without occurrence`, tok)
+				}
+				for _, n := range ordinals {
+					r.ByDay = append(r.ByDay, WeekdayNum{Ordinal: n, Weekday: wd})
+				}
+				weekdays = true
+			default:
+				return nil, errors.Errorf(`unexpected weekday '%s'`, tok)
+			}
+			continue
+		}
+
+		switch prefix {
+		case "D", "W":
+			if len(tok) != 4 {
+				return nil, errors.Errorf(`invalid time '%s'`, tok)
+			}
+			h, herr := strconv.Atoi(tok[:2])
+			m, merr := strconv.Atoi(tok[2:])
+			if herr != nil || merr != nil || h > 23 || m > 59 {
+				return nil, errors.Errorf(`invalid time '%s'`, tok)
+			}
+			r.ByHour = appendUniqueInt(r.ByHour, h)
+			r.ByMinute = appendUniqueInt(r.ByMinute, m)
+		case "MP":
+			n, err := parseVCal10Ordinal(tok, 5)
+			if err != nil {
+				return nil, err
+			}
+			if weekdays {
+				ordinals, weekdays = nil, false
+			}
+			ordinals = append(ordinals, n)
+		case "MD":
+			if tok == "LD" {
+				r.ByMonthDay = append(r.ByMonthDay, -1)
+				continue
+			}
+			n, err := parseVCal10Ordinal(tok, 31)
+			if err != nil {
+				return nil, err
+			}
+			r.ByMonthDay = append(r.ByMonthDay, n)
+		case "YM":
+			n, err := strconv.Atoi(tok)
+			if err != nil || n < 1 || n > 12 {
+				return nil, errors.Errorf(`invalid month '%s'`, tok)
+			}
+			r.ByMonth = append(r.ByMonth, n)
+		case "YD":
+			n, err := parseVCal10Ordinal(tok, 366)
+			if err != nil {
+				return nil, err
+			}
+			r.ByYearDay = append(r.ByYearDay, n)
+		}
+	}
+
+	if len(ordinals) > 0 && !weekdays {
+		return nil, errors.New(`occurrence without weekday`)
+	}
+	if !ended {
+		r.Count = 2
+	}
+	return &r, nil
+}
+
+// parseVCal10Ordinal parses a number that counts from the start ("2" or
+// "2+") or from the end ("2-") of a period
+func parseVCal10Ordinal(s string, max int) (int, error) {
+	sign := 1
+	switch {
+	case strings.HasSuffix(s, "+"):
+		s = s[:len(s)-1]
+	case strings.HasSuffix(s, "-"):
+		sign = -1
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > max {
+		return 0, errors.Errorf(`invalid ordinal '%s'`, s)
+	}
+	return sign * n, nil
+}
+
+func appendUniqueInt(l []int, n int) []int {
+	if containsInt(l, n) {
+		return l
+	}
+	return append(l, n)
+}
+
+// VCal10String returns the rule in the vCalendar 1.0 grammar. Rules that
+// use rule parts with no vCalendar 1.0 equivalent can not be converted
+func (r *Recur) VCal10String() (string, error) {
+	if len(r.BySecond) > 0 || len(r.ByWeekNo) > 0 || len(r.BySetPos) > 0 {
+		return "", errors.New(`recur rule can not be expressed in vCalendar 1.0`)
+	}
+
+	var prefix string
+	var parts []string
+	formatOrdinal := func(n int) string {
+		if n < 0 {
+			return strconv.Itoa(-n) + "-"
+		}
+		return strconv.Itoa(n) + "+"
+	}
+
+	switch r.Freq {
+	case FreqDaily, FreqWeekly:
+		prefix = "D"
+		if r.Freq == FreqWeekly {
+			prefix = "W"
+		}
+		if len(r.ByMonthDay) > 0 || len(r.ByYearDay) > 0 || len(r.ByMonth) > 0 {
+			return "", errors.Errorf(`%s recur rule can not be expressed in vCalendar 1.0`, r.Freq)
+		}
+		for _, wd := range r.ByDay {
+			if wd.Ordinal != 0 || r.Freq != FreqWeekly {
+				return "", errors.Errorf(`%s recur rule can not be expressed in vCalendar 1.0`, r.Freq)
+			}
+			parts = append(parts, wd.Weekday.String())
+		}
+		minutes := r.ByMinute
+		if len(minutes) == 0 && len(r.ByHour) > 0 {
+			minutes = []int{0}
+		}
+		if len(minutes) > 0 && len(r.ByHour) == 0 {
+			return "", errors.New(`BYMINUTE without BYHOUR can not be expressed in vCalendar 1.0`)
+		}
+		for _, h := range r.ByHour {
+			for _, m := range minutes {
+				parts = append(parts, twoDigits(h)+twoDigits(m))
+			}
+		}
+	case FreqMonthly:
+		if len(r.ByHour) > 0 || len(r.ByMinute) > 0 || len(r.ByYearDay) > 0 || len(r.ByMonth) > 0 {
+			return "", errors.Errorf(`%s recur rule can not be expressed in vCalendar 1.0`, r.Freq)
+		}
+		switch {
+		case len(r.ByDay) > 0 && len(r.ByMonthDay) > 0:
+			return "", errors.New(`BYDAY with BYMONTHDAY can not be expressed in vCalendar 1.0`)
+		case len(r.ByDay) > 0:
+			prefix = "MP"
+			for _, wd := range r.ByDay {
+				if wd.Ordinal == 0 || wd.Ordinal > 5 || wd.Ordinal < -5 {
+					return "", errors.New(`BYDAY without ordinal can not be expressed in vCalendar 1.0`)
+				}
+				parts = append(parts, formatOrdinal(wd.Ordinal), wd.Weekday.String())
+			}
+		default:
+			prefix = "MD"
+			for _, n := range r.ByMonthDay {
+				if n < 0 {
+					parts = append(parts, formatOrdinal(n))
+				} else {
+					parts = append(parts, strconv.Itoa(n))
+				}
+			}
+		}
+	case FreqYearly:
+		if len(r.ByHour) > 0 || len(r.ByMinute) > 0 || len(r.ByDay) > 0 || len(r.ByMonthDay) > 0 {
+			return "", errors.Errorf(`%s recur rule can not be expressed in vCalendar 1.0`, r.Freq)
+		}
+		switch {
+		case len(r.ByYearDay) > 0 && len(r.ByMonth) > 0:
+			return "", errors.New(`BYYEARDAY with BYMONTH can not be expressed in vCalendar 1.0`)
+		case len(r.ByYearDay) > 0:
+			prefix = "YD"
+			for _, n := range r.ByYearDay {
+				if n < 0 {
+					parts = append(parts, formatOrdinal(n))
+				} else {
+					parts = append(parts, strconv.Itoa(n))
+				}
+			}
+		default:
+			prefix = "YM"
+			for _, n := range r.ByMonth {
+				parts = append(parts, strconv.Itoa(n))
+			}
+		}
+	default:
+		return "", errors.Errorf(`%s recur rule can not be expressed in vCalendar 1.0`, r.Freq)
+	}
+
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	parts = append([]string{prefix + strconv.Itoa(interval)}, parts...)
+
+	switch {
+	case !r.Until.IsZero() && r.untilDate:
+		parts = append(parts, r.Until.Format(dateFormat))
+	case !r.Until.IsZero() && r.untilFloating:
+		parts = append(parts, r.Until.Format(dateTimeFormat))
+	case !r.Until.IsZero():
+		parts = append(parts, r.Until.UTC().Format(utcDateTimeFormat))
+	default:
+		parts = append(parts, "#"+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+func twoDigits(n int) string {
+	if n < 10 {
+		return "0" + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
+
+// vcal10ParameterName returns the name of a vCalendar 1.0 parameter that
+// was given by its value alone, such as ";QUOTED-PRINTABLE"
+func vcal10ParameterName(value string) string {
+	switch strings.ToUpper(value) {
+	case "7BIT", "8BIT", "QUOTED-PRINTABLE", "BASE64":
+		return "ENCODING"
+	case "INLINE", "URL", "CONTENT-ID", "CID":
+		return "VALUE"
+	}
+	return "TYPE"
+}
+
+// isQuotedPrintableLine reports whether the content line l has its
+// value encoded as QUOTED-PRINTABLE
+func isQuotedPrintableLine(l string) bool {
+	i := strings.IndexByte(l, ':')
+	if i < 0 {
+		return false
+	}
+	return strings.Contains(strings.ToUpper(l[:i]), "QUOTED-PRINTABLE")
+}
+
+// decodeQuotedPrintable decodes s, in which soft line breaks have already
+// been removed. Malformed escapes are retained as is
+func decodeQuotedPrintable(s string) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] == '=' && i+2 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				buf.WriteByte(byte(n))
+				i += 2
+				continue
+			}
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.Bytes()
+}
+
+// needsQuotedPrintable reports whether a vCalendar 1.0 value has to be
+// encoded as QUOTED-PRINTABLE to be written
+func needsQuotedPrintable(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < ' ' || c > '~' {
+			return true
+		}
+	}
+	return false
+}
+
+// writeQuotedPrintable encodes s as QUOTED-PRINTABLE into buf. Lines are
+// broken with soft line breaks so that no line exceeds 76 characters.
+// col is the length of the line written so far
+func writeQuotedPrintable(buf *bytes.Buffer, s string, col int, crlf string) {
+	const width = 76
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		var tok string
+		switch {
+		case c == '\r' && i+1 < len(s) && s[i+1] == '\n':
+			tok = "=0D=0A"
+			i++
+		case c == '\n':
+			tok = "=0D=0A"
+		case c == '=', c < ' ' || c > '~', c == ' ' && i == len(s)-1:
+			tok = "=" + strings.ToUpper(strconv.FormatUint(uint64(c), 16))
+			if len(tok) == 2 {
+				tok = "=0" + tok[1:]
+			}
+		default:
+			tok = string(c)
+		}
+
+		// keep room for the '=' of a soft line break
+		if col+len(tok) > width-1 {
+			buf.WriteString("=" + crlf)
+			col = 0
+		}
+		buf.WriteString(tok)
+		col += len(tok)
+	}
+}
+
+// VCal10Alarm converts a vCalendar 1.0 AALARM, DALARM or MALARM property
+// to the equivalent VALARM component
+func (p Property) VCal10Alarm() (*Alarm, error) {
+	parts := strings.Split(p.value, ";")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	for len(parts) < 4 {
+		parts = append(parts, "")
+	}
+
+	if parts[0] == "" {
+		return nil, errors.Errorf(`%s has no run time`, strings.ToUpper(p.name))
+	}
+	if _, isDate, err := parseDateTime(parts[0], time.UTC); err != nil || isDate {
+		return nil, errors.Errorf(`invalid run time '%s'`, parts[0])
+	}
+
+	a := NewAlarm()
+	content := strings.Join(parts[3:], ";")
+	switch p.name {
+	case "aalarm":
+		a.AddProperty("action", "AUDIO")
+		if content != "" {
+			var params Parameters
+			if typ, ok := p.params.Get("TYPE"); ok {
+				params = Parameters{"FMTTYPE": []string{"audio/" + strings.ToLower(typ)}}
+			}
+			a.AddProperty("attach", content, WithParameters(params))
+		}
+	case "dalarm":
+		a.AddProperty("action", "DISPLAY")
+		a.AddProperty("description", content)
+	case "malarm":
+		address, note := parts[3], strings.Join(parts[4:], ";")
+		if address == "" {
+			return nil, errors.New(`MALARM has no email address`)
+		}
+		a.AddProperty("action", "EMAIL")
+		a.AddProperty("attendee", vcal10CalAddress(address))
+		a.AddProperty("summary", note)
+		a.AddProperty("description", note)
+	default:
+		return nil, errors.Errorf(`%s is not a vCalendar 1.0 alarm`, strings.ToUpper(p.name))
+	}
+
+	a.AddProperty("trigger", parts[0], WithParameters(Parameters{"VALUE": []string{"DATE-TIME"}}))
+
+	if parts[1] != "" && parts[2] != "" {
+		if _, err := parseDuration(parts[1]); err != nil {
+			return nil, errors.Wrap(err, `invalid snooze time`)
+		}
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || n < 0 {
+			return nil, errors.Errorf(`invalid repeat count '%s'`, parts[2])
+		}
+		if n > 0 {
+			a.AddProperty("duration", parts[1])
+			a.AddProperty("repeat", parts[2])
+		}
+	}
+	return a, nil
+}
+
+// vcal10CalAddress converts a vCalendar 1.0 address, such as
+// "John Public <jpublic@example.com>", to a CAL-ADDRESS
+func vcal10CalAddress(s string) string {
+	if i := strings.IndexByte(s, '<'); i >= 0 {
+		if j := strings.IndexByte(s[i:], '>'); j > 0 {
+			s = s[i+1 : i+j]
+		}
+	}
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, ":") {
+		return s
+	}
+	return "mailto:" + s
+}
+
+// convertVCal10Property adds a property read from a vCalendar 1.0
+// calendar to e, converting it to its iCalendar 2.0 equivalent. Alarms
+// are converted to VALARM components
+func convertVCal10Property(e Entry, name, value string, params Parameters) error {
+	switch name {
+	case "version":
+		value = "2.0"
+	case "dcreated":
+		name = "created"
+	case "rrule", "exrule":
+		r, err := ParseVCal10Recur(value)
+		if err != nil {
+			return errors.Wrapf(err, `failed to convert %s`, strings.ToUpper(name))
+		}
+		value = r.String()
+	case "aalarm", "dalarm", "malarm":
+		a, err := Property{name: name, value: value, params: params}.VCal10Alarm()
+		if err != nil {
+			return errors.Wrapf(err, `failed to convert %s`, strings.ToUpper(name))
+		}
+		return e.AddEntry(a)
+	case "status":
+		value = strings.Replace(strings.ToUpper(value), " ", "-", -1)
+	case "categories", "resources":
+		values := strings.Split(value, ";")
+		return addParsedProperty(e, name, strings.Join(values, ","), params, values)
+	case "attendee", "organizer":
+		value = vcal10CalAddress(value)
+	}
+	return addParsedProperty(e, name, value, params, nil)
+}
+
+// vcal10Value returns the value of p as written in a vCalendar 1.0
+// calendar. RECUR values are converted to the vCalendar 1.0 grammar
+// where possible
+func vcal10Value(p *Property) string {
+	switch {
+	case p.values != nil:
+		return strings.Join(p.values, ";")
+	case !p.vcal10 && (p.name == "rrule" || p.name == "exrule"):
+		r, err := ParseRecur(p.value)
+		if err != nil {
+			return p.value
+		}
+		if s, err := r.VCal10String(); err == nil {
+			return s
+		}
+	}
+	return p.value
+}
+
+// vcal10Alarm converts a VALARM to the vCalendar 1.0 alarm property of
+// its parent component e. Only alarms whose trigger can be resolved to
+// an absolute time can be converted
+func vcal10Alarm(e Entry, a Entry) (*Property, bool) {
+	action, ok := a.GetProperty("action")
+	if !ok {
+		return nil, false
+	}
+	trigger, ok := a.GetProperty("trigger")
+	if !ok {
+		return nil, false
+	}
+
+	var t time.Time
+	if trigger.ValueType() == ValueDateTime {
+		v, err := trigger.Time(time.UTC)
+		if err != nil {
+			return nil, false
+		}
+		t = v
+	} else {
+		d, err := parseDuration(trigger.value)
+		if err != nil {
+			return nil, false
+		}
+		related := "dtstart"
+		if v, ok := trigger.params.Get("RELATED"); ok && strings.EqualFold(v, "END") {
+			related = "dtend"
+		}
+		base, isDate, ok, err := entryTime(e, related, time.UTC, loadLocation)
+		if err != nil || !ok || isDate {
+			return nil, false
+		}
+		t = d.addTo(base)
+	}
+
+	var snooze, repeat string
+	if d, ok := a.GetProperty("duration"); ok {
+		if r, ok := a.GetProperty("repeat"); ok {
+			snooze, repeat = d.value, r.value
+		}
+	}
+	parts := []string{t.UTC().Format(utcDateTimeFormat), snooze, repeat}
+
+	params := Parameters{}
+	var name string
+	switch strings.ToUpper(action.value) {
+	case "AUDIO":
+		name = "aalarm"
+		var content string
+		if attach, ok := a.GetProperty("attach"); ok {
+			content = attach.value
+			if typ, ok := attach.params.Get("FMTTYPE"); ok && strings.HasPrefix(typ, "audio/") {
+				params.Set("TYPE", strings.ToUpper(strings.TrimPrefix(typ, "audio/")))
+			}
+		}
+		parts = append(parts, content)
+	case "DISPLAY":
+		name = "dalarm"
+		var content string
+		if desc, ok := a.GetProperty("description"); ok {
+			content = desc.value
+		}
+		parts = append(parts, content)
+	case "EMAIL":
+		name = "malarm"
+		attendee, ok := a.GetProperty("attendee")
+		if !ok {
+			return nil, false
+		}
+		var note string
+		if desc, ok := a.GetProperty("description"); ok {
+			note = desc.value
+		}
+		parts = append(parts, strings.TrimPrefix(attendee.value, "mailto:"), note)
+	default:
+		return nil, false
+	}
+
+	p := newProperty(name, strings.Join(parts, ";"), params, nil)
+	p.vcal10 = true
+	return p, true
+}
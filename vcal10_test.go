@@ -0,0 +1,284 @@
+package ical_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+const vcal10Source = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:1.0\r\n" +
+	"PRODID:-//Example//Device//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:vcal10@example.com\r\n" +
+	"DTSTART:20240101T090000Z\r\n" +
+	"DTEND:20240101T100000Z\r\n" +
+	"SUMMARY;CHARSET=ISO-8859-1;ENCODING=QUOTED-PRINTABLE:Caf=E9\r\n" +
+	"DESCRIPTION;QUOTED-PRINTABLE;CHARSET=UTF-8:first line=0D=0Asecond =\r\n" +
+	"line =E6=97=A5=E6=9C=AC\r\n" +
+	"CATEGORIES:MEETING;WORK\r\n" +
+	"STATUS:NEEDS ACTION\r\n" +
+	"RRULE:W1 MO TU #10\r\n" +
+	"AALARM;TYPE=WAVE:20240101T085500Z;PT5M;2;file:///alarm.wav\r\n" +
+	"DALARM:20240101T085000Z;;;Meeting soon\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func vcal10Event(t *testing.T, c *ical.Calendar) *ical.Event {
+	for e := range c.Entries() {
+		if ev, ok := e.(*ical.Event); ok {
+			return ev
+		}
+	}
+	t.Fatal(`no event found`)
+	return nil
+}
+
+func TestVCal10Parse(t *testing.T) {
+	c, err := ical.NewParser().Parse(strings.NewReader(vcal10Source))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+	ev := vcal10Event(t, c)
+
+	expect := map[string]string{
+		"summary":     "Café",
+		"description": "first line\r\nsecond line 日本",
+		"categories":  "MEETING;WORK",
+		"rrule":       "W1 MO TU #10",
+	}
+	for name, value := range expect {
+		p, ok := ev.GetProperty(name)
+		if !assert.True(t, ok, `property %s should exist`, name) {
+			return
+		}
+		if !assert.Equal(t, value, p.RawValue(), `value of %s should match`, name) {
+			return
+		}
+		if _, ok := p.Parameters().Get("ENCODING"); !assert.False(t, ok, `ENCODING of %s should be removed`, name) {
+			return
+		}
+	}
+
+	p, _ := ev.GetProperty("rrule")
+	r, err := p.Recur()
+	if !assert.NoError(t, err, `Recur should succeed`) {
+		return
+	}
+	if !assert.Equal(t, "FREQ=WEEKLY;COUNT=10;BYDAY=MO,TU", r.String(), `rule should match`) {
+		return
+	}
+
+	occurrences, err := c.Occurrences(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	if !assert.NoError(t, err, `Occurrences should succeed`) {
+		return
+	}
+	if !assert.Len(t, occurrences, 10, `rule should be expanded`) {
+		return
+	}
+
+	p, _ = ev.GetProperty("aalarm")
+	a, err := p.VCal10Alarm()
+	if !assert.NoError(t, err, `VCal10Alarm should succeed`) {
+		return
+	}
+	if !assert.Equal(t, "BEGIN:VALARM\r\n"+
+		"ACTION:AUDIO\r\n"+
+		"ATTACH;FMTTYPE=audio/wave:file:///alarm.wav\r\n"+
+		"DURATION:PT5M\r\n"+
+		"REPEAT:2\r\n"+
+		"TRIGGER;VALUE=DATE-TIME:20240101T085500Z\r\n"+
+		"END:VALARM\r\n", a.String(), `alarm should match`) {
+		return
+	}
+
+	// properties are written back in vCalendar 1.0 syntax
+	var buf bytes.Buffer
+	if !assert.NoError(t, ical.NewEncoder(&buf).Encode(c), `Encode should succeed`) {
+		return
+	}
+	for _, l := range []string{
+		"VERSION:1.0\r\n",
+		"CATEGORIES:MEETING;WORK\r\n",
+		"RRULE:W1 MO TU #10\r\n",
+		"SUMMARY;CHARSET=UTF-8;ENCODING=QUOTED-PRINTABLE:Caf=C3=A9\r\n",
+		"DESCRIPTION;CHARSET=UTF-8;ENCODING=QUOTED-PRINTABLE:first line=0D=0Asecond =\r\nline =E6=97=A5=E6=9C=AC\r\n",
+		"AALARM;TYPE=WAVE:20240101T085500Z;PT5M;2;file:///alarm.wav\r\n",
+	} {
+		if !assert.Contains(t, buf.String(), l, `output should contain %q`, l) {
+			return
+		}
+	}
+
+	c2, err := ical.NewParser().Parse(&buf)
+	if !assert.NoError(t, err, `Parse of the output should succeed`) {
+		return
+	}
+	ev2 := vcal10Event(t, c2)
+	for name, value := range expect {
+		p, ok := ev2.GetProperty(name)
+		if !assert.True(t, ok, `property %s should exist after round trip`, name) {
+			return
+		}
+		if !assert.Equal(t, value, p.RawValue(), `value of %s should survive the round trip`, name) {
+			return
+		}
+	}
+}
+
+func TestVCal10VersionAfterProperties(t *testing.T) {
+	src := "BEGIN:VCALENDAR\r\n" +
+		"X-WR-CALNAME;ENCODING=QUOTED-PRINTABLE;CHARSET=UTF-8:Caf=C3=A9 =\r\n" +
+		"du coin\r\n" +
+		"PRODID:-//Example//Device//EN\r\n" +
+		"VERSION:1.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:vcal10@example.com\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+	p, ok := c.GetProperty("x-wr-calname")
+	if !assert.True(t, ok, `property should exist`) {
+		return
+	}
+	if !assert.Equal(t, "Café du coin", p.RawValue(), `property before VERSION should be decoded as vCalendar 1.0`) {
+		return
+	}
+	if _, ok := p.Parameters().Get("ENCODING"); !assert.False(t, ok, `ENCODING should be removed`) {
+		return
+	}
+}
+
+func TestVCal10Convert(t *testing.T) {
+	c, err := ical.NewParser(ical.WithConvertVCal10(true)).Parse(strings.NewReader(vcal10Source))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, ical.NewEncoder(&buf).Encode(c), `Encode should succeed`) {
+		return
+	}
+	expect := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"PRODID:-//Example//Device//EN\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"CATEGORIES:MEETING,WORK\r\n" +
+		"DESCRIPTION:first line\\nsecond line 日本\r\n" +
+		"DTEND:20240101T100000Z\r\n" +
+		"DTSTART:20240101T090000Z\r\n" +
+		"RRULE:FREQ=WEEKLY;COUNT=10;BYDAY=MO,TU\r\n" +
+		"STATUS:NEEDS-ACTION\r\n" +
+		"SUMMARY:Café\r\n" +
+		"UID:vcal10@example.com\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"ACTION:AUDIO\r\n" +
+		"ATTACH;FMTTYPE=audio/wave:file:///alarm.wav\r\n" +
+		"DURATION:PT5M\r\n" +
+		"REPEAT:2\r\n" +
+		"TRIGGER;VALUE=DATE-TIME:20240101T085500Z\r\n" +
+		"END:VALARM\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"ACTION:DISPLAY\r\n" +
+		"DESCRIPTION:Meeting soon\r\n" +
+		"TRIGGER;VALUE=DATE-TIME:20240101T085000Z\r\n" +
+		"END:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	if !assert.Equal(t, expect, buf.String(), `converted calendar should match`) {
+		return
+	}
+}
+
+func TestVCal10Write(t *testing.T) {
+	c := ical.New(ical.WithVCal10(true))
+	e := ical.NewEvent()
+	e.AddProperty("uid", "write@example.com")
+	e.AddProperty("dtstart", "20240105T120000Z")
+	e.AddProperty("summary", "a;b, c")
+	e.AddProperty("rrule", "FREQ=MONTHLY;COUNT=3;BYDAY=1FR,-1FR")
+	a := ical.NewAlarm()
+	a.AddProperty("action", "DISPLAY")
+	a.AddProperty("description", "reminder")
+	a.AddProperty("trigger", "-PT15M")
+	e.AddEntry(a)
+	c.AddEntry(e)
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, ical.NewEncoder(&buf).Encode(c), `Encode should succeed`) {
+		return
+	}
+	for _, l := range []string{
+		"SUMMARY:a;b, c\r\n",
+		"RRULE:MP1 1+ FR 1- FR #3\r\n",
+		"DALARM:20240105T114500Z;;;reminder\r\n",
+	} {
+		if !assert.Contains(t, buf.String(), l, `output should contain %q`, l) {
+			return
+		}
+	}
+	if !assert.NotContains(t, buf.String(), "BEGIN:VALARM", `alarm should be converted`) {
+		return
+	}
+}
+
+func TestVCal10Recur(t *testing.T) {
+	for _, tc := range []struct {
+		vcal10 string
+		ical   string
+		format string
+	}{
+		{"D2 #5", "FREQ=DAILY;COUNT=5;INTERVAL=2", ""},
+		{"D1 0800 1700 #0", "FREQ=DAILY;BYMINUTE=0;BYHOUR=8,17", "D1 0800 1700 #0"},
+		{"W1 MO TU #10", "FREQ=WEEKLY;COUNT=10;BYDAY=MO,TU", ""},
+		{"W2 FR", "FREQ=WEEKLY;COUNT=2;INTERVAL=2;BYDAY=FR", "W2 FR #2"},
+		{"MP1 1+ 2+ MO 1- SU$ #4", "FREQ=MONTHLY;COUNT=4;BYDAY=1MO,2MO,-1SU", "MP1 1+ MO 2+ MO 1- SU #4"},
+		{"MD1 1 15 1- 20241231T000000Z", "FREQ=MONTHLY;UNTIL=20241231T000000Z;BYMONTHDAY=1,15,-1", "MD1 1 15 1- 20241231T000000Z"},
+		{"MD1 LD #0", "FREQ=MONTHLY;BYMONTHDAY=-1", "MD1 1- #0"},
+		{"YM1 6 7 #3", "FREQ=YEARLY;COUNT=3;BYMONTH=6,7", ""},
+		{"YD3 1 100 #0", "FREQ=YEARLY;INTERVAL=3;BYYEARDAY=1,100", ""},
+	} {
+		r, err := ical.ParseVCal10Recur(tc.vcal10)
+		if !assert.NoError(t, err, `ParseVCal10Recur(%q) should succeed`, tc.vcal10) {
+			return
+		}
+		if !assert.Equal(t, tc.ical, r.String(), `rule %q should match`, tc.vcal10) {
+			return
+		}
+		format := tc.format
+		if format == "" {
+			format = tc.vcal10
+		}
+		s, err := r.VCal10String()
+		if !assert.NoError(t, err, `VCal10String should succeed`) {
+			return
+		}
+		if !assert.Equal(t, format, s, `rule %q should be formatted`, tc.vcal10) {
+			return
+		}
+	}
+
+	for _, s := range []string{"", "X1 #1", "W0", "W1 XX", "MP1 MO", "MP1 1+", "D1 #1 #2", "YM1 13"} {
+		_, err := ical.ParseVCal10Recur(s)
+		if !assert.Error(t, err, `ParseVCal10Recur(%q) should fail`, s) {
+			return
+		}
+	}
+
+	r, err := ical.ParseRecur("FREQ=HOURLY")
+	if !assert.NoError(t, err, `ParseRecur should succeed`) {
+		return
+	}
+	_, err = r.VCal10String()
+	if !assert.Error(t, err, `hourly rules can not be expressed`) {
+		return
+	}
+}
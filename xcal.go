@@ -0,0 +1,464 @@
+package ical
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const xcalNamespace = "urn:ietf:params:xml:ns:icalendar-2.0"
+
+// XMLEncoder encodes entries as xCal (RFC 6321)
+type XMLEncoder struct {
+	dst *xml.Encoder
+	w   io.Writer
+}
+
+// XMLDecoder decodes xCal (RFC 6321) into entries
+type XMLDecoder struct {
+	src *xml.Decoder
+}
+
+func NewXMLEncoder(dst io.Writer) *XMLEncoder {
+	enc := xml.NewEncoder(dst)
+	enc.Indent("", " ")
+	return &XMLEncoder{
+		dst: enc,
+		w:   dst,
+	}
+}
+
+// xcalParameterTypes lists the parameters whose value is not TEXT
+var xcalParameterTypes = map[string]ValueType{
+	"altrep":         ValueURI,
+	"delegated-from": ValueCalAddress,
+	"delegated-to":   ValueCalAddress,
+	"dir":            ValueURI,
+	"member":         ValueCalAddress,
+	"sent-by":        ValueCalAddress,
+}
+
+// Encode writes e as an xCal document. Calendars are wrapped in the
+// icalendar root element, other components are written as is
+func (enc *XMLEncoder) Encode(e Entry) error {
+	if e.Type() == "VCALENDAR" {
+		if _, err := io.WriteString(enc.w, xml.Header); err != nil {
+			return errors.Wrap(err, `failed to write xml header`)
+		}
+		root := xml.StartElement{
+			Name: xml.Name{Local: "icalendar"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: xcalNamespace}},
+		}
+		if err := enc.dst.EncodeToken(root); err != nil {
+			return errors.Wrap(err, `failed to write icalendar element`)
+		}
+		if err := enc.encodeComponent(e); err != nil {
+			return err
+		}
+		if err := enc.dst.EncodeToken(root.End()); err != nil {
+			return errors.Wrap(err, `failed to write icalendar element`)
+		}
+	} else if err := enc.encodeComponent(e); err != nil {
+		return err
+	}
+
+	if err := enc.dst.Flush(); err != nil {
+		return errors.Wrap(err, `failed to flush xml`)
+	}
+	_, err := io.WriteString(enc.w, "\n")
+	return err
+}
+
+func (enc *XMLEncoder) start(name string) error {
+	return enc.dst.EncodeToken(xml.StartElement{Name: xml.Name{Local: name}})
+}
+
+func (enc *XMLEncoder) end(name string) error {
+	return enc.dst.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+func (enc *XMLEncoder) element(name, value string) error {
+	return enc.dst.EncodeElement(value, xml.StartElement{Name: xml.Name{Local: name}})
+}
+
+func (enc *XMLEncoder) encodeComponent(e Entry) error {
+	name := strings.ToLower(e.Type())
+	if err := enc.start(name); err != nil {
+		return errors.Wrapf(err, `failed to write %s element`, name)
+	}
+
+	if err := enc.start("properties"); err != nil {
+		return errors.Wrap(err, `failed to write properties element`)
+	}
+	for p := range e.Properties() {
+		if err := enc.encodeProperty(p); err != nil {
+			return errors.Wrapf(err, `failed to encode property %s`, p.Name())
+		}
+	}
+	if err := enc.end("properties"); err != nil {
+		return errors.Wrap(err, `failed to write properties element`)
+	}
+
+	var started bool
+	for sub := range e.Entries() {
+		if !started {
+			if err := enc.start("components"); err != nil {
+				return errors.Wrap(err, `failed to write components element`)
+			}
+			started = true
+		}
+		if err := enc.encodeComponent(sub); err != nil {
+			return err
+		}
+	}
+	if started {
+		if err := enc.end("components"); err != nil {
+			return errors.Wrap(err, `failed to write components element`)
+		}
+	}
+
+	return enc.end(name)
+}
+
+func (enc *XMLEncoder) encodeProperty(p *Property) error {
+	inlined, err := p.inline()
+	if err != nil {
+		return errors.Wrapf(err, `failed to encode property '%s'`, p.Name())
+	}
+	p = inlined
+	if err := enc.start(p.Name()); err != nil {
+		return err
+	}
+
+	var pnames []string
+	for k, v := range p.params {
+		if len(v) > 0 && !strings.EqualFold(k, "VALUE") {
+			pnames = append(pnames, k)
+		}
+	}
+	if len(pnames) > 0 {
+		sort.Strings(pnames)
+		if err := enc.start("parameters"); err != nil {
+			return err
+		}
+		for _, k := range pnames {
+			pname := strings.ToLower(k)
+			vt, ok := xcalParameterTypes[pname]
+			if !ok {
+				vt = ValueText
+			}
+			if err := enc.start(pname); err != nil {
+				return err
+			}
+			for _, v := range p.params[k] {
+				if err := enc.element(strings.ToLower(string(vt)), v); err != nil {
+					return err
+				}
+			}
+			if err := enc.end(pname); err != nil {
+				return err
+			}
+		}
+		if err := enc.end("parameters"); err != nil {
+			return err
+		}
+	}
+
+	if err := enc.encodeValue(p); err != nil {
+		return err
+	}
+	return enc.end(p.Name())
+}
+
+func (enc *XMLEncoder) encodeValue(p *Property) error {
+	vt := p.ValueType()
+	typ := strings.ToLower(string(vt))
+	if _, ok := jcalTypes[vt]; !ok {
+		typ = "unknown"
+	}
+
+	switch {
+	case p.name == "geo" && vt == ValueFloat:
+		parts := strings.SplitN(p.value, ";", 2)
+		if len(parts) == 2 {
+			if err := enc.element("latitude", parts[0]); err != nil {
+				return err
+			}
+			return enc.element("longitude", parts[1])
+		}
+	case p.name == "request-status" && vt == ValueText:
+		names := []string{"code", "description", "data"}
+		for i, v := range splitStructuredText(p.value) {
+			if i >= len(names) {
+				break
+			}
+			if err := enc.element(names[i], v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch vt {
+	case ValueText:
+		for _, v := range p.Values() {
+			if err := enc.element(typ, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ValueDate, ValueDateTime, ValueTime:
+		for _, v := range strings.Split(p.value, ",") {
+			if err := enc.element(typ, jcalDateTime(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ValuePeriod:
+		for _, v := range strings.Split(p.value, ",") {
+			if err := enc.encodePeriod(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ValueRecur:
+		return enc.encodeRecur(p.value)
+	case ValueUTCOffset:
+		return enc.element(typ, jcalUTCOffset(p.value))
+	case ValueBoolean:
+		return enc.element(typ, strings.ToLower(p.value))
+	case ValueInteger:
+		for _, v := range strings.Split(p.value, ",") {
+			if err := enc.element(typ, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return enc.element(typ, p.value)
+}
+
+func (enc *XMLEncoder) encodePeriod(v string) error {
+	if err := enc.start("period"); err != nil {
+		return err
+	}
+
+	i := strings.IndexByte(v, '/')
+	if i < 0 {
+		return errors.Errorf(`invalid period value '%s'`, v)
+	}
+	if err := enc.element("start", jcalDateTime(v[:i])); err != nil {
+		return err
+	}
+	if end := v[i+1:]; end != "" && strings.ContainsAny(end[:1], "P+-") {
+		if err := enc.element("duration", end); err != nil {
+			return err
+		}
+	} else if err := enc.element("end", jcalDateTime(end)); err != nil {
+		return err
+	}
+	return enc.end("period")
+}
+
+func (enc *XMLEncoder) encodeRecur(v string) error {
+	if err := enc.start("recur"); err != nil {
+		return err
+	}
+	for _, part := range strings.Split(v, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		name := strings.ToLower(kv[0])
+		for _, rv := range strings.Split(kv[1], ",") {
+			if name == "until" {
+				rv = jcalDateTime(rv)
+			}
+			if err := enc.element(name, rv); err != nil {
+				return err
+			}
+		}
+	}
+	return enc.end("recur")
+}
+
+func NewXMLDecoder(src io.Reader) *XMLDecoder {
+	return &XMLDecoder{
+		src: xml.NewDecoder(src),
+	}
+}
+
+// xcalNode is a generic XML element, used to walk xCal documents
+type xcalNode struct {
+	XMLName xml.Name
+	Nodes   []*xcalNode `xml:",any"`
+	Text    string      `xml:",chardata"`
+}
+
+func (n *xcalNode) name() string {
+	return n.XMLName.Local
+}
+
+func (n *xcalNode) child(name string) *xcalNode {
+	for _, c := range n.Nodes {
+		if c.name() == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Decode decodes the first vcalendar component of an xCal document
+func (dec *XMLDecoder) Decode() (*Calendar, error) {
+	var root xcalNode
+	if err := dec.src.Decode(&root); err != nil {
+		return nil, errors.Wrap(err, `failed to decode xml`)
+	}
+
+	if root.name() == "icalendar" {
+		vcal := root.child("vcalendar")
+		if vcal == nil {
+			return nil, errors.New(`icalendar element contains no vcalendar`)
+		}
+		root = *vcal
+	}
+	if root.name() != "vcalendar" {
+		return nil, errors.Errorf(`expected vcalendar, got %s`, root.name())
+	}
+
+	e, err := decodeXCalComponent(&root)
+	if err != nil {
+		return nil, err
+	}
+	return e.(*Calendar), nil
+}
+
+func decodeXCalComponent(n *xcalNode) (Entry, error) {
+	e := newEntry(n.name())
+	if props := n.child("properties"); props != nil {
+		for _, p := range props.Nodes {
+			if err := decodeXCalProperty(e, p); err != nil {
+				return nil, errors.Wrapf(err, `failed to decode property in %s`, n.name())
+			}
+		}
+	}
+
+	if comps := n.child("components"); comps != nil {
+		for _, c := range comps.Nodes {
+			sub, err := decodeXCalComponent(c)
+			if err != nil {
+				return nil, err
+			}
+			if err := e.AddEntry(sub); err != nil {
+				return nil, errors.Wrapf(err, `failed to add component to %s`, n.name())
+			}
+		}
+	}
+	return e, nil
+}
+
+func decodeXCalProperty(e Entry, n *xcalNode) error {
+	name := strings.ToLower(n.name())
+	params := Parameters{}
+	var valueNodes []*xcalNode
+	for _, c := range n.Nodes {
+		if c.name() != "parameters" {
+			valueNodes = append(valueNodes, c)
+			continue
+		}
+		for _, param := range c.Nodes {
+			for _, v := range param.Nodes {
+				params.Add(strings.ToUpper(param.name()), v.Text)
+			}
+		}
+	}
+
+	if len(valueNodes) == 0 {
+		return errors.Errorf(`property %s has no value`, name)
+	}
+
+	// structured values are written without a value type element
+	switch valueNodes[0].name() {
+	case "latitude", "longitude":
+		var lat, lon string
+		for _, v := range valueNodes {
+			switch v.name() {
+			case "latitude":
+				lat = v.Text
+			case "longitude":
+				lon = v.Text
+			}
+		}
+		return addParsedProperty(e, name, lat+";"+lon, params, nil)
+	case "code", "description", "data":
+		var parts []string
+		for _, v := range valueNodes {
+			var buf bytes.Buffer
+			escapeText(&buf, v.Text)
+			parts = append(parts, buf.String())
+		}
+		return addParsedProperty(e, name, strings.Join(parts, ";"), params, nil)
+	}
+
+	typ := valueNodes[0].name()
+	vt := ValueType(strings.ToUpper(typ))
+	if typ != "unknown" {
+		def, ok := defaultValueTypes[name]
+		if !ok {
+			def = ValueText
+		}
+		if vt != def {
+			params.Set("VALUE", string(vt))
+		}
+	}
+
+	var values []string
+	for _, v := range valueNodes {
+		switch vt {
+		case ValueDate, ValueDateTime, ValueTime:
+			values = append(values, icalDateTime(v.Text))
+		case ValueUTCOffset:
+			values = append(values, strings.Replace(v.Text, ":", "", -1))
+		case ValueBoolean:
+			values = append(values, strings.ToUpper(v.Text))
+		case ValuePeriod:
+			var s string
+			if start := v.child("start"); start != nil {
+				s = icalDateTime(start.Text) + "/"
+			}
+			if end := v.child("end"); end != nil {
+				s += icalDateTime(end.Text)
+			} else if d := v.child("duration"); d != nil {
+				s += d.Text
+			}
+			values = append(values, s)
+		case ValueRecur:
+			var parts []string
+			var last string
+			for _, part := range v.Nodes {
+				pv := part.Text
+				if part.name() == "until" {
+					pv = icalDateTime(pv)
+				}
+				if part.name() == last {
+					parts[len(parts)-1] += "," + pv
+					continue
+				}
+				parts = append(parts, strings.ToUpper(part.name())+"="+pv)
+				last = part.name()
+			}
+			values = append(values, strings.Join(parts, ";"))
+		default:
+			values = append(values, v.Text)
+		}
+	}
+
+	if vt == ValueText && isTextList(name) {
+		return addParsedProperty(e, name, "", params, values)
+	}
+	return addParsedProperty(e, name, strings.Join(values, ","), params, nil)
+}
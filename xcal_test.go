@@ -0,0 +1,156 @@
+package ical_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	ical "github.com/lestrrat-go/ical"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXCal(t *testing.T) {
+	// example from RFC 6321 appendix B.1
+	src := `<?xml version="1.0" encoding="utf-8"?>
+<icalendar xmlns="urn:ietf:params:xml:ns:icalendar-2.0">
+ <vcalendar>
+  <properties>
+   <calscale><text>GREGORIAN</text></calscale>
+   <prodid>
+    <text>-//Example Inc.//Example Calendar//EN</text>
+   </prodid>
+   <version><text>2.0</text></version>
+  </properties>
+  <components>
+   <vevent>
+    <properties>
+     <dtstamp>
+       <date-time>2008-02-05T19:12:24Z</date-time>
+     </dtstamp>
+     <dtstart><date>2008-10-06</date></dtstart>
+     <summary>
+      <text>Planning meeting</text>
+     </summary>
+     <uid>
+      <text>4088E990AD89CB3DBB484909</text>
+     </uid>
+    </properties>
+   </vevent>
+  </components>
+ </vcalendar>
+</icalendar>`
+
+	c, err := ical.NewXMLDecoder(strings.NewReader(src)).Decode()
+	if !assert.NoError(t, err, `Decode should succeed`) {
+		return
+	}
+
+	expect := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`CALSCALE:GREGORIAN`,
+		`PRODID:-//Example Inc.//Example Calendar//EN`,
+		`BEGIN:VEVENT`,
+		`DTSTAMP:20080205T191224Z`,
+		`DTSTART;VALUE=DATE:20081006`,
+		`SUMMARY:Planning meeting`,
+		`UID:4088E990AD89CB3DBB484909`,
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+	if !assert.Equal(t, expect, c.String(), `decoded calendar should match`) {
+		return
+	}
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, ical.NewXMLEncoder(&buf).Encode(c), `Encode should succeed`) {
+		return
+	}
+
+	for _, s := range []string{
+		`<icalendar xmlns="urn:ietf:params:xml:ns:icalendar-2.0">`,
+		`<dtstamp>`,
+		`<date-time>2008-02-05T19:12:24Z</date-time>`,
+		`<date>2008-10-06</date>`,
+		`<text>Planning meeting</text>`,
+	} {
+		if !assert.Contains(t, buf.String(), s, `xcal output should contain element`) {
+			return
+		}
+	}
+
+	c2, err := ical.NewXMLDecoder(&buf).Decode()
+	if !assert.NoError(t, err, `Decode should succeed`) {
+		return
+	}
+	if !assert.Equal(t, expect, c2.String(), `round trip should match`) {
+		return
+	}
+}
+
+func TestXCalValues(t *testing.T) {
+	src := strings.Join([]string{
+		`BEGIN:VCALENDAR`,
+		`VERSION:2.0`,
+		`PRODID:-//Example//EN`,
+		`BEGIN:VTIMEZONE`,
+		`TZID:America/New_York`,
+		`BEGIN:STANDARD`,
+		`DTSTART:19701101T020000`,
+		`RRULE:FREQ=YEARLY;BYDAY=1SU;BYMONTH=11`,
+		`TZOFFSETFROM:-0400`,
+		`TZOFFSETTO:-0500`,
+		`END:STANDARD`,
+		`END:VTIMEZONE`,
+		`BEGIN:VEVENT`,
+		`ATTENDEE;DELEGATED-FROM="mailto:jsmith@example.com":mailto:jdoe@example.com`,
+		`CATEGORIES:WORK,PROJECT\, X`,
+		`DTSTART;TZID=America/New_York:20200106T100000`,
+		`EXDATE:20200113T150000Z,20200120T150000Z`,
+		`GEO:37.386013;-122.082932`,
+		`PRIORITY:1`,
+		`RDATE;VALUE=PERIOD:20200201T100000Z/PT1H`,
+		`REQUEST-STATUS:2.0;Success`,
+		`RRULE:FREQ=WEEKLY;UNTIL=20200301T000000Z;BYDAY=MO,WE`,
+		`X-FLAG;VALUE=BOOLEAN:TRUE`,
+		`END:VEVENT`,
+		`END:VCALENDAR`,
+	}, "\r\n") + "\r\n"
+
+	c, err := ical.NewParser().Parse(strings.NewReader(src))
+	if !assert.NoError(t, err, `Parse should succeed`) {
+		return
+	}
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, ical.NewXMLEncoder(&buf).Encode(c), `Encode should succeed`) {
+		return
+	}
+
+	out := strings.NewReplacer("\n", "", " ", "").Replace(buf.String())
+	for _, s := range []string{
+		`<attendee><parameters><delegated-from><cal-address>mailto:jsmith@example.com</cal-address></delegated-from></parameters><cal-address>mailto:jdoe@example.com</cal-address></attendee>`,
+		`<categories><text>WORK</text><text>PROJECT,X</text></categories>`,
+		`<dtstart><parameters><tzid><text>America/New_York</text></tzid></parameters><date-time>2020-01-06T10:00:00</date-time></dtstart>`,
+		`<exdate><date-time>2020-01-13T15:00:00Z</date-time><date-time>2020-01-20T15:00:00Z</date-time></exdate>`,
+		`<geo><latitude>37.386013</latitude><longitude>-122.082932</longitude></geo>`,
+		`<priority><integer>1</integer></priority>`,
+		`<rdate><period><start>2020-02-01T10:00:00Z</start><duration>PT1H</duration></period></rdate>`,
+		`<request-status><code>2.0</code><description>Success</description></request-status>`,
+		`<rrule><recur><freq>WEEKLY</freq><until>2020-03-01T00:00:00Z</until><byday>MO</byday><byday>WE</byday></recur></rrule>`,
+		`<tzoffsetto><utc-offset>-05:00</utc-offset></tzoffsetto>`,
+		`<x-flag><boolean>true</boolean></x-flag>`,
+	} {
+		if !assert.Contains(t, out, s, `xcal output should contain property`) {
+			return
+		}
+	}
+
+	c2, err := ical.NewXMLDecoder(&buf).Decode()
+	if !assert.NoError(t, err, `Decode should succeed`) {
+		return
+	}
+	if !assert.Equal(t, src, c2.String(), `round trip should match`) {
+		return
+	}
+}